@@ -0,0 +1,288 @@
+package main
+
+// This file contains the matching for binary contents (when the content type hint is BINARY).
+// The contents are compared as bytes rather than being parsed, and matching rules apply either
+// to the whole body ($) or to a range of bytes, e.g. $[12..16] for the bytes from offset 12 up
+// to (but not including) offset 16, or $[3] for the single byte at offset 3. Mismatches are
+// reported with the same offset paths.
+//
+// The supported rules are:
+//
+//   equality                       the bytes must be equal (the default)
+//   type                           any bytes are allowed, as long as the range is present
+//   min, max, minType, maxType     the length of the body must be within the bounds
+//   prefix                         the bytes must start with the hex "value", e.g. a magic number
+//   mask                           the bytes under the hex "mask" must equal the expected bytes,
+//                                  or the hex "value" if given
+//   contentType                    the content type detected from the bytes must match
+//
+// as well as the matchers defined by the plugin (see custommatchers.go)
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	plugin "github.com/pact-foundation/pact-plugin-template-golang/io_pact_plugin"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Most bytes shown in a mismatch message. The full bytes are shown in the mismatch diff
+const maxDisplayBytes = 32
+
+var byteRangeRegex = regexp.MustCompile(`^\$\[(\d+)(?:\.\.(\d+))?\]$`)
+
+// byteRange is a range of offsets in binary contents, from start up to (but not including) end
+type byteRange struct {
+	start int
+	end   int
+}
+
+func (r byteRange) String() string {
+	return fmt.Sprintf("$[%d..%d]", r.start, r.end)
+}
+
+func (r byteRange) contains(offset int) bool {
+	return offset >= r.start && offset < r.end
+}
+
+// parseByteRange parses a binary path expression, either $[start..end] or $[offset]
+func parseByteRange(expression string) (byteRange, error) {
+	m := byteRangeRegex.FindStringSubmatch(expression)
+	if m == nil {
+		return byteRange{}, fmt.Errorf("'%s' is not a byte range, expected $[start..end] or $[offset]", expression)
+	}
+
+	start, err := strconv.Atoi(m[1])
+	if err != nil {
+		return byteRange{}, fmt.Errorf("invalid byte range '%s': %w", expression, err)
+	}
+	end := start + 1
+	if m[2] != "" {
+		end, err = strconv.Atoi(m[2])
+		if err != nil {
+			return byteRange{}, fmt.Errorf("invalid byte range '%s': %w", expression, err)
+		}
+	}
+	if end <= start {
+		return byteRange{}, fmt.Errorf("invalid byte range '%s': the end must be after the start", expression)
+	}
+
+	return byteRange{start: start, end: end}, nil
+}
+
+// compareBinary compares binary contents. The rules for byte ranges are applied to those ranges.
+// Without rules for the whole body, the rest of the bytes must be equal, and each run of
+// differing bytes is reported as a mismatch.
+func (c *comparison) compareBinary(expected []byte, actual []byte) []*plugin.ContentMismatch {
+	var mismatches []*plugin.ContentMismatch
+
+	bodyRules := c.rules["$"].GetRule()
+	for _, rule := range bodyRules {
+		if err := applyBinaryRule(rule, 0, expected, actual); err != nil {
+			mismatches = append(mismatches, c.binaryMismatch("$", expected, actual, err.Error()))
+		}
+	}
+
+	var ranges []byteRange
+	for _, expression := range sortedKeys(c.rules) {
+		if expression == "$" || len(c.rules[expression].GetRule()) == 0 {
+			continue
+		}
+		r, err := parseByteRange(expression)
+		if err != nil {
+			log.Println("[WARN] ignoring matching rules for binary contents:", err)
+			continue
+		}
+		ranges = append(ranges, r)
+
+		if r.end > len(actual) {
+			mismatches = append(mismatches, c.binaryMismatch(r.String(), sliceRange(expected, r), sliceRange(actual, r),
+				fmt.Sprintf("expected at least %d byte(s) but received %d", r.end, len(actual))))
+			continue
+		}
+		for _, rule := range c.rules[expression].GetRule() {
+			if err := applyBinaryRule(rule, r.start, sliceRange(expected, r), actual[r.start:r.end]); err != nil {
+				mismatches = append(mismatches, c.binaryMismatch(r.String(), sliceRange(expected, r), actual[r.start:r.end], err.Error()))
+			}
+		}
+	}
+
+	if len(bodyRules) == 0 {
+		if len(expected) != len(actual) {
+			mismatches = append(mismatches, c.binaryMismatch("$", expected, actual,
+				fmt.Sprintf("expected %d byte(s) but received %d", len(expected), len(actual))))
+		}
+		mismatches = append(mismatches, c.differingBytes(expected, actual, ranges)...)
+	}
+
+	return mismatches
+}
+
+// differingBytes reports each run of bytes that differ, ignoring the ranges that have their own rules
+func (c *comparison) differingBytes(expected []byte, actual []byte, ignored []byteRange) []*plugin.ContentMismatch {
+	var mismatches []*plugin.ContentMismatch
+
+	n := len(expected)
+	if len(actual) < n {
+		n = len(actual)
+	}
+
+	differs := func(offset int) bool {
+		for _, r := range ignored {
+			if r.contains(offset) {
+				return false
+			}
+		}
+		return expected[offset] != actual[offset]
+	}
+
+	for offset := 0; offset < n; offset++ {
+		if !differs(offset) {
+			continue
+		}
+		r := byteRange{start: offset, end: offset + 1}
+		for r.end < n && differs(r.end) {
+			r.end++
+		}
+
+		mismatches = append(mismatches, c.binaryMismatch(r.String(), expected[r.start:r.end], actual[r.start:r.end],
+			fmt.Sprintf("expected bytes %s but received %s", displayBytes(expected[r.start:r.end]), displayBytes(actual[r.start:r.end]))))
+		offset = r.end
+	}
+
+	return mismatches
+}
+
+// applyBinaryRule applies a single matching rule to the actual bytes at the offset, returning an
+// error describing the mismatch if it doesn't match
+func applyBinaryRule(rule *plugin.MatchingRule, offset int, expected []byte, actual []byte) error {
+	values := ruleValues(rule)
+
+	switch ruleType(rule) {
+	case "equality":
+		if !bytes.Equal(expected, actual) {
+			return fmt.Errorf("expected bytes %s but received %s", displayBytes(expected), displayBytes(actual))
+		}
+
+	case "type":
+		// Any bytes are allowed
+
+	case "min", "max", "mintype", "maxtype", "minmaxtype":
+		if min, ok := integerValue(values["min"]); ok && int64(len(actual)) < min {
+			return fmt.Errorf("expected at least %d byte(s) but received %d", min, len(actual))
+		}
+		if max, ok := integerValue(values["max"]); ok && int64(len(actual)) > max {
+			return fmt.Errorf("expected at most %d byte(s) but received %d", max, len(actual))
+		}
+
+	case "prefix":
+		prefix, err := hexValue(values["value"])
+		if err != nil {
+			return fmt.Errorf("invalid prefix rule: %w", err)
+		}
+		if !bytes.HasPrefix(actual, prefix) {
+			return fmt.Errorf("expected the bytes to start with %s but received %s",
+				displayBytes(prefix), displayBytes(actual[:minInt(len(actual), len(prefix))]))
+		}
+
+	case "mask":
+		mask, err := hexValue(values["mask"])
+		if err != nil {
+			return fmt.Errorf("invalid mask rule: %w", err)
+		}
+		want := expected
+		if v, ok := values["value"]; ok {
+			if want, err = hexValue(v); err != nil {
+				return fmt.Errorf("invalid mask rule: %w", err)
+			}
+		}
+		if len(want) < len(mask) {
+			return fmt.Errorf("invalid mask rule: the mask is %d byte(s) but the expected value is %d", len(mask), len(want))
+		}
+		if len(actual) < len(mask) {
+			return fmt.Errorf("expected at least %d byte(s) for the mask but received %d", len(mask), len(actual))
+		}
+		for i := range mask {
+			if actual[i]&mask[i] != want[i]&mask[i] {
+				return fmt.Errorf("expected the byte at offset %d to be %02x under the mask %02x but was %02x",
+					offset+i, want[i]&mask[i], mask[i], actual[i])
+			}
+		}
+
+	case "contenttype":
+		return matchContentType(fmt.Sprint(values["value"]), string(actual))
+
+	default:
+		if m, ok := findCustomMatcher(ruleType(rule)); ok {
+			return m.Match(values, expected, actual)
+		}
+		return fmt.Errorf("unsupported matching rule type '%s' for binary contents", rule.Type)
+	}
+
+	return nil
+}
+
+// binaryMismatch builds a mismatch for a range of binary contents
+func (c *comparison) binaryMismatch(path string, expected []byte, actual []byte, description string) *plugin.ContentMismatch {
+	return &plugin.ContentMismatch{
+		Expected: wrapperspb.Bytes(expected),
+		Actual:   wrapperspb.Bytes(actual),
+		Mismatch: description,
+		Path:     path,
+		Diff:     hexDiff(expected, actual),
+	}
+}
+
+// sliceRange returns the bytes in the range, or as much of it as there is
+func sliceRange(b []byte, r byteRange) []byte {
+	return b[minInt(r.start, len(b)):minInt(r.end, len(b))]
+}
+
+// hexValue parses a rule value given as hex digits, optionally separated by spaces, e.g. "89 50 4e 47"
+func hexValue(v interface{}) ([]byte, error) {
+	s, ok := v.(string)
+	if !ok || s == "" {
+		return nil, fmt.Errorf("expected a hex string but got %v", v)
+	}
+
+	b, err := hex.DecodeString(strings.ReplaceAll(strings.TrimPrefix(s, "0x"), " ", ""))
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is not a valid hex string: %w", s, err)
+	}
+
+	return b, nil
+}
+
+// displayBytes formats bytes as hex for a mismatch message, truncating long values
+func displayBytes(b []byte) string {
+	if len(b) == 0 {
+		return "[]"
+	}
+
+	var s strings.Builder
+	for i, v := range b {
+		if i == maxDisplayBytes {
+			fmt.Fprintf(&s, " ... (%d bytes)", len(b))
+			break
+		}
+		if i > 0 {
+			s.WriteByte(' ')
+		}
+		fmt.Fprintf(&s, "%02x", v)
+	}
+
+	return "[" + s.String() + "]"
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
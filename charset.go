@@ -0,0 +1,97 @@
+package main
+
+// This file decodes text contents using the charset parameter of their content type, so that
+// contents in other encodings (e.g. UTF-16, ISO-8859-1, Shift_JIS or EBCDIC) are matched as text
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	plugin "github.com/pact-foundation/pact-plugin-template-golang/io_pact_plugin"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Byte order marks, which are not part of the text
+var byteOrderMarks = [][]byte{{0xef, 0xbb, 0xbf}, {0xfe, 0xff}, {0xff, 0xfe}}
+
+// decodeText decodes the body from the charset of the content type to UTF-8. Bodies without a
+// charset are returned as is. It is an error for the body to contain byte sequences that are
+// invalid in the charset, rather than them being silently replaced.
+func decodeText(body []byte, contentType string) ([]byte, error) {
+	charset := contentCharset(contentType)
+	if charset == "" {
+		return body, nil
+	}
+
+	enc, err := charsetEncoding(charset)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s text: %w", charset, err)
+	}
+
+	// The decoders replace invalid byte sequences with U+FFFD. As the replacement character
+	// may also be in the text, it is only an error if the text doesn't encode back to the body
+	if n := bytes.IndexRune(decoded, utf8.RuneError); n >= 0 {
+		encoded, err := enc.NewEncoder().Bytes(decoded)
+		if err != nil || !bytes.Equal(trimByteOrderMark(encoded), trimByteOrderMark(body)) {
+			return nil, fmt.Errorf("invalid %s text: invalid byte sequence at character %d", charset, utf8.RuneCount(decoded[:n])+1)
+		}
+	}
+
+	return decoded, nil
+}
+
+// contentCharset returns the charset parameter of the content type, if any. Invalid content
+// types are ignored here, as they are reported when the content types are compared
+func contentCharset(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+
+	m, err := parseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+
+	return m.Params["charset"]
+}
+
+// charsetEncoding looks up the encoding for an IANA charset name or alias
+func charsetEncoding(charset string) (encoding.Encoding, error) {
+	enc, err := ianaindex.IANA.Encoding(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unknown charset '%s'", charset)
+	}
+	if enc == nil {
+		return nil, fmt.Errorf("unsupported charset '%s'", charset)
+	}
+
+	return enc, nil
+}
+
+func trimByteOrderMark(b []byte) []byte {
+	for _, bom := range byteOrderMarks {
+		if bytes.HasPrefix(b, bom) {
+			return b[len(bom):]
+		}
+	}
+
+	return b
+}
+
+// decodeMismatch reports contents that could not be decoded as a mismatch of the whole body
+func decodeMismatch(expected []byte, actual []byte, err error) *plugin.ContentMismatch {
+	return &plugin.ContentMismatch{
+		Expected: wrapperspb.Bytes(expected),
+		Actual:   wrapperspb.Bytes(actual),
+		Mismatch: fmt.Sprintf("unable to decode the actual contents: %s", err),
+		Path:     "$",
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+// This file contains the matching rules that apply to arrays and objects as a whole:
+// min/max length, eachValue, eachKey, arrayContains and notEmpty
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/pact-foundation/pact-plugin-template-golang/docpath"
+	plugin "github.com/pact-foundation/pact-plugin-template-golang/io_pact_plugin"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// findRule returns the first rule of the given type
+func findRule(rules []*plugin.MatchingRule, t string) *plugin.MatchingRule {
+	for _, rule := range rules {
+		if ruleType(rule) == t {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+// inheritedRules converts the rules defined on a parent into the rules that cascade to its children.
+// The length constraints only apply to the array itself, so the children are matched by type, and
+// eachValue passes its rules on to the values. eachKey and arrayContains are not inherited.
+func inheritedRules(rules []*plugin.MatchingRule) []*plugin.MatchingRule {
+	var inherited []*plugin.MatchingRule
+
+	for _, rule := range rules {
+		switch ruleType(rule) {
+		case "min", "max", "mintype", "maxtype", "minmaxtype", "notempty":
+			inherited = append(inherited, &plugin.MatchingRule{Type: "type"})
+		case "eachvalue":
+			inherited = append(inherited, inheritedRules(ruleDefinitions(ruleValues(rule)["rules"]))...)
+		case "eachkey", "arraycontains":
+		default:
+			inherited = append(inherited, rule)
+		}
+	}
+
+	return inherited
+}
+
+// matchLength checks the length of an array against the min and max values of a rule
+func matchLength(values map[string]interface{}, actual []interface{}) error {
+	if min, ok := integerValue(values["min"]); ok && int64(len(actual)) < min {
+		return fmt.Errorf("expected an array with at least %d item(s) but received %d", min, len(actual))
+	}
+	if max, ok := integerValue(values["max"]); ok && int64(len(actual)) > max {
+		return fmt.Errorf("expected an array with at most %d item(s) but received %d", max, len(actual))
+	}
+
+	return nil
+}
+
+// matchNotEmpty checks the value is present and not empty
+func matchNotEmpty(actual interface{}) error {
+	empty := false
+	switch v := actual.(type) {
+	case nil:
+		empty = true
+	case string:
+		empty = v == ""
+	case []interface{}:
+		empty = len(v) == 0
+	case map[string]interface{}:
+		empty = len(v) == 0
+	}
+
+	if empty {
+		return fmt.Errorf("expected %s to not be empty", displayValue(actual))
+	}
+
+	return nil
+}
+
+// arrayContains checks that, for each variant of the rule, the actual array contains an item
+// matching the expected item at the variant's index, using the variant's own rules
+func (c *comparison) arrayContains(path docpath.Concrete, rule *plugin.MatchingRule, expected []interface{}, actual []interface{}) []*plugin.ContentMismatch {
+	var mismatches []*plugin.ContentMismatch
+	variants, _ := ruleValues(rule)["variants"].([]interface{})
+
+	for n, v := range variants {
+		variant, _ := v.(map[string]interface{})
+		index, ok := integerValue(variant["index"])
+		if !ok || index < 0 || int(index) >= len(expected) {
+			mismatches = append(mismatches, c.mismatch(path, expected, actual,
+				fmt.Sprintf("arrayContains variant %d refers to an invalid index %v", n, variant["index"])))
+			continue
+		}
+
+		variantComparison := comparison{
+			rules:               ruleCategory(variant["rules"]),
+			allowUnexpectedKeys: c.allowUnexpectedKeys,
+		}
+
+		found := false
+		for _, a := range actual {
+			if len(variantComparison.compare(docpath.RootPath(), expected[index], a)) == 0 {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			mismatches = append(mismatches, c.mismatch(path, expected[index], actual,
+				fmt.Sprintf("expected the array to contain an item matching variant %d (%s)", n, displayValue(expected[index]))))
+		}
+	}
+
+	return mismatches
+}
+
+// matchKeys applies the rules of an eachKey matcher to each key of the object
+func (c *comparison) matchKeys(path docpath.Concrete, rule *plugin.MatchingRule, actual map[string]interface{}) []*plugin.ContentMismatch {
+	var mismatches []*plugin.ContentMismatch
+	rules := ruleDefinitions(ruleValues(rule)["rules"])
+
+	for _, key := range sortedKeys(actual) {
+		childPath := path.Field(key)
+		for _, r := range rules {
+			if err := applyRule(r, key, key); err != nil {
+				mismatches = append(mismatches, c.mismatch(childPath, nil, key, fmt.Sprintf("key '%s': %s", key, err)))
+			}
+		}
+	}
+
+	return mismatches
+}
+
+// ruleDefinitions converts a list of matcher definitions (e.g. [{"match": "regex", "regex": "\\d+"}])
+// as used by eachValue and eachKey into matching rules
+func ruleDefinitions(definitions interface{}) []*plugin.MatchingRule {
+	list, _ := definitions.([]interface{})
+	rules := make([]*plugin.MatchingRule, 0, len(list))
+
+	for _, d := range list {
+		definition, ok := d.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		rule, err := matchingRuleFromDefinition(definition)
+		if err != nil {
+			log.Println("[WARN] ignoring invalid matching rule definition:", err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// ruleCategory converts a map of path expressions to matcher definitions, as used by the
+// arrayContains variants, into matching rules. Each entry is either a list of definitions
+// or an object with a "matchers" list.
+func ruleCategory(category interface{}) map[string]*plugin.MatchingRules {
+	m, _ := category.(map[string]interface{})
+	rules := make(map[string]*plugin.MatchingRules, len(m))
+
+	for expression, definitions := range m {
+		if list, ok := definitions.(map[string]interface{}); ok {
+			definitions = list["matchers"]
+		}
+		rules[expression] = &plugin.MatchingRules{Rule: ruleDefinitions(definitions)}
+	}
+
+	return rules
+}
+
+// matchingRuleFromDefinition converts a matcher definition as found in a pact file, where the "match"
+// key holds the rule type and the other keys are its values, into a matching rule
+func matchingRuleFromDefinition(definition map[string]interface{}) (*plugin.MatchingRule, error) {
+	values := make(map[string]interface{})
+	for k, v := range definition {
+		if k != "match" {
+			values[k] = v
+		}
+	}
+
+	ruleValues, err := structpb.NewStruct(values)
+	if err != nil {
+		return nil, err
+	}
+
+	t, ok := definition["match"].(string)
+	if !ok {
+		return nil, fmt.Errorf("matcher definition %v has no 'match' type", definition)
+	}
+
+	return &plugin.MatchingRule{
+		Type:   t,
+		Values: ruleValues,
+	}, nil
+}
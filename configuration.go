@@ -1,9 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"log"
 
+	"github.com/pact-foundation/pact-plugin-template-golang/docpath"
+	plugin "github.com/pact-foundation/pact-plugin-template-golang/io_pact_plugin"
+
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
@@ -14,32 +19,87 @@ import (
 type configuration struct {
 	Request  configurationRequest
 	Response configurationResponse
+
+	// Optional limit on the number of mismatches reported for each path, persisted
+	// to the pact so that it also applies to the mock server and the verifier
+	MaxMismatchesPerPath int
+
+	// Optional leniency when comparing content types: strict, parameters (the default) or
+	// lenient (see contenttype.go). Also persisted to the pact
+	ContentTypeMatching string
+
+	// Optional seed for the generators, so that the generated contents are the same on every run.
+	// Also persisted to the pact
+	GeneratorSeed *int64
+
+	// Optional fixed time (an RFC 3339 timestamp) relative to which dates and times are generated,
+	// so that they are the same on every run too. Also persisted to the pact
+	GeneratorTime string
 }
 
 type configurationRequest struct {
-	Body string
+	Body configurationBody
 }
 
 type configurationResponse struct {
-	Body string
+	Body configurationBody
+
+	// Optional delays and faults the mock server applies when responding (see faults.go)
+	Transport transportBehaviour
+}
+
+// The body of a part. It is either a string, which is the contents as text, or a JSON document.
+// Any string in it may be a matching expression, e.g. "matching(type, 'Fred')" (see expressions.go)
+type configurationBody struct {
+	value interface{}
+}
+
+func (b *configurationBody) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(&b.value)
+}
+
+func (b configurationBody) empty() bool {
+	return b.value == nil || b.value == ""
+}
+
+// contents returns the contents of the part, with the example values of any matching expressions
+// in it, and the matching rules and generators they define, keyed by path
+func (b configurationBody) contents() ([]byte, map[string]*plugin.MatchingRules, map[string]*plugin.Generator, error) {
+	rules := make(map[string]*plugin.MatchingRules)
+	generators := make(map[string]*plugin.Generator)
+
+	value, err := expandExpressions(docpath.RootPath(), b.value, rules, generators)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	content, err := marshalContents(value)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return content, rules, generators, nil
 }
 
 // Converts a protobuf Struct (essentially an arbitrary structure)
-// to a configuration item.
+// to a configuration item. A setting of the wrong type (e.g. a fractional
+// maxMismatchesPerPath or a numeric transport delay) is an error, rather
+// than being silently ignored
 func protoStructToConfigMap(s *structpb.Struct) (configuration, error) {
 	var config configuration
 	bytes, err := s.MarshalJSON()
 
 	if err != nil {
 		log.Println("ERROR marshalling ContentsConfig to JSON:", err)
-		return config, nil
+		return config, err
 	}
 
 	err = json.Unmarshal(bytes, &config)
 
 	if err != nil {
 		log.Println("ERROR unmarshalling ContentsConfig from JSON:", err)
-		return config, nil
+		return config, fmt.Errorf("invalid contents configuration: %w", err)
 	}
 
 	return config, nil
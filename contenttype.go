@@ -0,0 +1,174 @@
+package main
+
+// This file contains the content type negotiation: parsing the content types given to
+// the plugin, matching them against the content types it declares in the catalogue, and
+// comparing the expected and actual content types in CompareContents
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"os"
+	"strings"
+)
+
+// Key in the pact configuration, and environment variable (which takes precedence),
+// used to set how strictly content types are compared
+const (
+	contentTypeMatchingKey    = "contentTypeMatching"
+	contentTypeMatchingEnvVar = "CONTENT_TYPE_MATCHING"
+)
+
+// How strictly the expected and actual content types are compared
+const (
+	// The types and all the parameters must be equal
+	contentTypeStrict = "strict"
+
+	// The types must be equal, and every parameter of the expected content type must
+	// have the same value in the actual one. This is the default
+	contentTypeParameters = "parameters"
+
+	// Parameters are ignored, and different types are allowed if they are both
+	// content types the plugin supports (e.g. application/foo and application/vnd.bar+foo)
+	contentTypeLenient = "lenient"
+)
+
+// mediaType is a parsed content type, e.g. application/vnd.bar+foo; charset=utf-8
+type mediaType struct {
+	Type    string
+	Subtype string
+	Params  map[string]string
+}
+
+// parseMediaType parses a content type. The type, subtype and parameter names are case-insensitive,
+// so they are lower-cased, as is the charset parameter
+func parseMediaType(contentType string) (mediaType, error) {
+	essence, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return mediaType{}, fmt.Errorf("invalid content type '%s': %w", contentType, err)
+	}
+
+	t, subtype, ok := strings.Cut(essence, "/")
+	if !ok || t == "" || subtype == "" {
+		return mediaType{}, fmt.Errorf("invalid content type '%s': expected a type and subtype", contentType)
+	}
+	if charset, ok := params["charset"]; ok {
+		params["charset"] = strings.ToLower(charset)
+	}
+
+	return mediaType{Type: t, Subtype: subtype, Params: params}, nil
+}
+
+func (m mediaType) essence() string {
+	return m.Type + "/" + m.Subtype
+}
+
+// matches checks the media type against a pattern, where the type or subtype may be a wildcard,
+// including a structured syntax suffix wildcard such as application/*+foo
+func (m mediaType) matches(pattern mediaType) bool {
+	if pattern.Type != "*" && pattern.Type != m.Type {
+		return false
+	}
+
+	switch {
+	case pattern.Subtype == "*":
+		return true
+	case strings.HasPrefix(pattern.Subtype, "*+"):
+		return strings.HasSuffix(m.Subtype, pattern.Subtype[1:])
+	default:
+		return pattern.Subtype == m.Subtype
+	}
+}
+
+// supportedContentType checks the content type matches one of the content types declared in the catalogue
+func supportedContentType(contentType string) bool {
+	m, err := parseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, supported := range CONTENT_TYPES {
+		pattern, err := parseMediaType(supported)
+		if err == nil && m.matches(pattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compareContentTypes checks the actual content type is compatible with the expected one
+func compareContentTypes(expected string, actual string, leniency string) error {
+	e, err := parseMediaType(expected)
+	if err != nil {
+		return err
+	}
+	a, err := parseMediaType(actual)
+	if err != nil {
+		return err
+	}
+
+	if leniency == contentTypeLenient {
+		if e.essence() == a.essence() || (supportedContentType(expected) && supportedContentType(actual)) {
+			return nil
+		}
+		return fmt.Errorf("expected content type '%s' but received '%s'", expected, actual)
+	}
+
+	if e.essence() != a.essence() {
+		return fmt.Errorf("expected content type '%s' but received '%s'", e.essence(), a.essence())
+	}
+
+	for _, name := range sortedKeys(e.Params) {
+		value, ok := a.Params[name]
+		if !ok {
+			return fmt.Errorf("expected content type parameter '%s=%s' but it was missing", name, e.Params[name])
+		}
+		if value != e.Params[name] {
+			return fmt.Errorf("expected content type parameter '%s=%s' but received '%s=%s'", name, e.Params[name], name, value)
+		}
+	}
+
+	if leniency == contentTypeStrict {
+		for _, name := range sortedKeys(a.Params) {
+			if _, ok := e.Params[name]; !ok {
+				return fmt.Errorf("unexpected content type parameter '%s=%s'", name, a.Params[name])
+			}
+		}
+	}
+
+	return nil
+}
+
+// validContentTypeMatching checks the leniency is one of the supported values
+func validContentTypeMatching(leniency string) error {
+	switch leniency {
+	case contentTypeStrict, contentTypeParameters, contentTypeLenient:
+		return nil
+	default:
+		return fmt.Errorf("%s must be one of '%s', '%s' or '%s', got '%s'", contentTypeMatchingKey,
+			contentTypeStrict, contentTypeParameters, contentTypeLenient, leniency)
+	}
+}
+
+// contentTypeMatching returns how strictly content types are compared, from the environment
+// or otherwise the value configured in the pact
+func contentTypeMatching(configured interface{}) string {
+	if env := os.Getenv(contentTypeMatchingEnvVar); env != "" {
+		if err := validContentTypeMatching(env); err == nil {
+			return env
+		}
+		log.Println("[WARN] ignoring invalid", contentTypeMatchingEnvVar, "value:", env)
+	}
+
+	if configured == nil {
+		return contentTypeParameters
+	}
+	leniency, _ := configured.(string)
+	if err := validContentTypeMatching(leniency); err != nil {
+		log.Println("[WARN] ignoring invalid", contentTypeMatchingKey, "value:", configured)
+		return contentTypeParameters
+	}
+
+	return leniency
+}
@@ -0,0 +1,160 @@
+package main
+
+// This file contains the matching rule types defined by the plugin, in addition to the standard
+// Pact ones. They are advertised to the framework as MATCHER entries in the plugin catalogue, and
+// applied by CompareContents whenever a rule of their type appears in the matching rules.
+//
+// TODO: add your own domain specific matchers to customMatchers
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"strings"
+)
+
+// customMatcher is a matching rule type defined by the plugin
+type customMatcher struct {
+	// The rule type, as used in the matching rules (e.g. {"match": "luhn"})
+	Name        string
+	Description string
+
+	// Match checks the actual value (which is the raw bytes for binary contents), returning an
+	// error describing the mismatch if it doesn't match. values is the configuration of the rule
+	Match func(values map[string]interface{}, expected interface{}, actual interface{}) error
+}
+
+var customMatchers = []customMatcher{
+	{
+		Name:        "luhn",
+		Description: "The value is a number (e.g. a card number) with a valid Luhn check digit",
+		Match:       matchLuhn,
+	},
+	{
+		Name:        "iso-currency",
+		Description: "The value is an ISO 4217 currency code (e.g. EUR)",
+		Match:       matchCurrency,
+	},
+	{
+		Name:        "checksum",
+		Description: "The checksum of the value, using the given algorithm (crc32, md5, sha1 or sha256), is the hex value given",
+		Match:       matchChecksum,
+	},
+}
+
+// findCustomMatcher finds the matcher for a rule type, which is normalised in the same way as the
+// standard rule types, so "iso-currency", "isoCurrency" and "ISO_CURRENCY" are all the same matcher
+func findCustomMatcher(t string) (customMatcher, bool) {
+	for _, m := range customMatchers {
+		if normaliseRuleType(m.Name) == t {
+			return m, true
+		}
+	}
+
+	return customMatcher{}, false
+}
+
+func matchLuhn(values map[string]interface{}, expected interface{}, actual interface{}) error {
+	s, ok := primitiveString(actual)
+	if !ok {
+		return fmt.Errorf("expected %s to be a number with a valid Luhn check digit", displayValue(actual))
+	}
+	digits := strings.ReplaceAll(s, " ", "")
+	if len(digits) < 2 {
+		return fmt.Errorf("expected %s to be a number with a valid Luhn check digit", displayValue(actual))
+	}
+
+	sum := 0
+	for i := range digits {
+		c := digits[len(digits)-1-i]
+		if c < '0' || c > '9' {
+			return fmt.Errorf("expected %s to be a number with a valid Luhn check digit", displayValue(actual))
+		}
+		d := int(c - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+
+	if sum%10 != 0 {
+		return fmt.Errorf("expected %s to have a valid Luhn check digit", displayValue(actual))
+	}
+
+	return nil
+}
+
+func matchCurrency(values map[string]interface{}, expected interface{}, actual interface{}) error {
+	s, ok := actual.(string)
+	if !ok || !currencyCodes[s] {
+		return fmt.Errorf("expected %s to be an ISO 4217 currency code", displayValue(actual))
+	}
+
+	return nil
+}
+
+func matchChecksum(values map[string]interface{}, expected interface{}, actual interface{}) error {
+	algorithm, _ := values["algorithm"].(string)
+	var h hash.Hash
+	switch strings.ToLower(algorithm) {
+	case "crc32":
+		h = crc32.NewIEEE()
+	case "md5":
+		h = md5.New()
+	case "sha1":
+		h = sha1.New()
+	case "sha256", "":
+		h = sha256.New()
+	default:
+		return fmt.Errorf("unsupported checksum algorithm '%s'", algorithm)
+	}
+
+	want, _ := values["value"].(string)
+	if want == "" {
+		return fmt.Errorf("the checksum rule requires a hex value")
+	}
+
+	switch a := actual.(type) {
+	case []byte:
+		h.Write(a)
+	case string:
+		h.Write([]byte(a))
+	case json.Number:
+		h.Write([]byte(a.String()))
+	default:
+		b, _ := json.Marshal(a)
+		h.Write(b)
+	}
+
+	if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, want) {
+		return fmt.Errorf("expected the %s checksum to be '%s' but was '%s'", strings.ToLower(algorithm), want, sum)
+	}
+
+	return nil
+}
+
+// The active ISO 4217 currency codes
+var currencyCodes = func() map[string]bool {
+	codes := make(map[string]bool)
+	for _, code := range strings.Fields(`
+		AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND BOB BOV BRL BSD BTN BWP BYN BZD
+		CAD CDF CHE CHF CHW CLF CLP CNY COP COU CRC CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL
+		GHS GIP GMD GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY KES KGS KHR KMF KPW KRW KWD
+		KYD KZT LAK LBP LKR LRD LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MXV MYR MZN NAD NGN NIO
+		NOK NPR NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD RUB RWF SAR SBD SCR SDG SEK SGD SHP SLE SOS SRD
+		SSP STN SVC SYP SZL THB TJS TMT TND TOP TRY TTD TWD TZS UAH UGX USD USN UYI UYU UYW UZS VED VES VND VUV
+		WST XAF XAG XAU XBA XBB XBC XBD XCD XCG XDR XOF XPD XPF XPT XSU XTS XUA XXX YER ZAR ZMW ZWG
+	`) {
+		codes[code] = true
+	}
+
+	return codes
+}()
@@ -0,0 +1,344 @@
+package main
+
+// This file converts the date/time patterns used in Pact matching rules and generators
+// (Java DateTimeFormatter style, e.g. "yyyy-MM-dd'T'HH:mm:ss") into Go time layouts
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats used by the date, time and datetime matchers and generators when none is given
+const (
+	defaultDateFormat     = "yyyy-MM-dd"
+	defaultTimeFormat     = "HH:mm:ss"
+	defaultDateTimeFormat = "yyyy-MM-dd'T'HH:mm:ssXXX"
+)
+
+// goLayout converts a Java style date/time pattern into a Go time layout
+func goLayout(pattern string) (string, error) {
+	var layout strings.Builder
+	runes := []rune(pattern)
+
+	for i := 0; i < len(runes); {
+		c := runes[i]
+
+		// Quoted literal text, where '' is an escaped quote
+		if c == '\'' {
+			if i+1 < len(runes) && runes[i+1] == '\'' {
+				layout.WriteRune('\'')
+				i += 2
+				continue
+			}
+
+			var literal strings.Builder
+			j := i + 1
+			for {
+				if j >= len(runes) {
+					return "", fmt.Errorf("unterminated quote in date/time pattern '%s'", pattern)
+				}
+				if runes[j] == '\'' {
+					if j+1 < len(runes) && runes[j+1] == '\'' {
+						literal.WriteRune('\'')
+						j += 2
+						continue
+					}
+					break
+				}
+				literal.WriteRune(runes[j])
+				j++
+			}
+
+			if err := writeLiteral(&layout, literal.String(), pattern); err != nil {
+				return "", err
+			}
+			i = j + 1
+			continue
+		}
+
+		if !isPatternLetter(c) {
+			if err := writeLiteral(&layout, string(c), pattern); err != nil {
+				return "", err
+			}
+			i++
+			continue
+		}
+
+		// Count the run of the same pattern letter
+		n := 1
+		for i+n < len(runes) && runes[i+n] == c {
+			n++
+		}
+		i += n
+
+		token, err := layoutToken(c, n)
+		if err != nil {
+			return "", fmt.Errorf("unsupported date/time pattern '%s': %w", pattern, err)
+		}
+		layout.WriteString(token)
+	}
+
+	return layout.String(), nil
+}
+
+func isPatternLetter(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// writeLiteral adds literal text to the layout. Digits can't be represented in a
+// Go layout, as they would be interpreted as part of the layout
+func writeLiteral(layout *strings.Builder, literal string, pattern string) error {
+	if strings.ContainsAny(literal, "0123456789_") {
+		return fmt.Errorf("unsupported literal text '%s' in date/time pattern '%s'", literal, pattern)
+	}
+	layout.WriteString(literal)
+
+	return nil
+}
+
+// layoutToken maps a run of n pattern letters c to the equivalent Go layout element
+func layoutToken(c rune, n int) (string, error) {
+	switch c {
+	case 'y', 'u', 'Y':
+		if n == 2 {
+			return "06", nil
+		}
+		return "2006", nil
+	case 'M', 'L':
+		switch {
+		case n >= 4:
+			return "January", nil
+		case n == 3:
+			return "Jan", nil
+		case n == 2:
+			return "01", nil
+		default:
+			return "1", nil
+		}
+	case 'd':
+		if n >= 2 {
+			return "02", nil
+		}
+		return "2", nil
+	case 'E':
+		if n >= 4 {
+			return "Monday", nil
+		}
+		return "Mon", nil
+	case 'a':
+		return "PM", nil
+	case 'H', 'k':
+		return "15", nil
+	case 'h', 'K':
+		if n >= 2 {
+			return "03", nil
+		}
+		return "3", nil
+	case 'm':
+		if n >= 2 {
+			return "04", nil
+		}
+		return "4", nil
+	case 's':
+		if n >= 2 {
+			return "05", nil
+		}
+		return "5", nil
+	case 'S':
+		// Fractional seconds must follow a '.' or ',' literal in Go layouts
+		return strings.Repeat("0", n), nil
+	case 'z':
+		return "MST", nil
+	case 'Z':
+		if n >= 5 {
+			return "-07:00", nil
+		}
+		return "-0700", nil
+	case 'X':
+		switch {
+		case n >= 3:
+			return "Z07:00", nil
+		case n == 2:
+			return "Z0700", nil
+		default:
+			return "Z07", nil
+		}
+	case 'x':
+		switch {
+		case n >= 3:
+			return "-07:00", nil
+		case n == 2:
+			return "-0700", nil
+		default:
+			return "-07", nil
+		}
+	default:
+		return "", fmt.Errorf("pattern letter '%c' is not supported", c)
+	}
+}
+
+// parseWithPattern parses value using a Java style date/time pattern
+func parseWithPattern(pattern string, value string) (time.Time, error) {
+	layout, err := goLayout(pattern)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Parse(layout, value)
+}
+
+// Units of the offsets in date/time expressions, e.g. "+ 2 days"
+var expressionUnits = map[string]func(t time.Time, n int) time.Time{
+	"year":        func(t time.Time, n int) time.Time { return addMonths(t, 12*n) },
+	"month":       addMonths,
+	"fortnight":   func(t time.Time, n int) time.Time { return t.AddDate(0, 0, 14*n) },
+	"week":        func(t time.Time, n int) time.Time { return t.AddDate(0, 0, 7*n) },
+	"day":         func(t time.Time, n int) time.Time { return t.AddDate(0, 0, n) },
+	"hour":        func(t time.Time, n int) time.Time { return t.Add(time.Duration(n) * time.Hour) },
+	"minute":      func(t time.Time, n int) time.Time { return t.Add(time.Duration(n) * time.Minute) },
+	"second":      func(t time.Time, n int) time.Time { return t.Add(time.Duration(n) * time.Second) },
+	"millisecond": func(t time.Time, n int) time.Time { return t.Add(time.Duration(n) * time.Millisecond) },
+}
+
+// evaluateExpression evaluates a date/time expression, as used by the Date, Time and DateTime
+// generators, relative to base. An expression is a base date or time followed by offsets, e.g.
+//
+//	today, tomorrow, yesterday, now, midnight, noon, 2 o'clock, 11 o'clock pm
+//	+ 1 day, - 2 weeks + 3 days, next month, last year, + 1 hour - 15 minutes
+//
+// and a date and time expression can be combined with '@', e.g. "tomorrow @ noon"
+func evaluateExpression(expression string, base time.Time) (time.Time, error) {
+	t := base
+	for _, part := range strings.Split(expression, "@") {
+		var err error
+		t, err = evaluateExpressionPart(part, t)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date/time expression '%s': %w", expression, err)
+		}
+	}
+
+	return t, nil
+}
+
+func evaluateExpressionPart(expression string, t time.Time) (time.Time, error) {
+	tokens := expressionTokens.FindAllString(strings.ToLower(expression), -1)
+	if strings.TrimSpace(expressionTokens.ReplaceAllString(strings.ToLower(expression), "")) != "" {
+		return t, fmt.Errorf("unexpected characters")
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		switch token := tokens[i]; token {
+		case "now", "today":
+		case "tomorrow":
+			t = t.AddDate(0, 0, 1)
+		case "yesterday":
+			t = t.AddDate(0, 0, -1)
+		case "midnight":
+			t = atTime(t, 0)
+		case "noon":
+			t = atTime(t, 12)
+
+		case "next", "last":
+			if i+1 >= len(tokens) {
+				return t, fmt.Errorf("expected a unit after '%s'", token)
+			}
+			add, ok := expressionUnit(tokens[i+1])
+			if !ok {
+				return t, fmt.Errorf("unknown unit '%s'", tokens[i+1])
+			}
+			n := 1
+			if token == "last" {
+				n = -1
+			}
+			t = add(t, n)
+			i++
+
+		case "+", "-":
+			if i+2 >= len(tokens) {
+				return t, fmt.Errorf("expected a number and unit after '%s'", token)
+			}
+			n, err := strconv.Atoi(tokens[i+1])
+			if err != nil {
+				return t, fmt.Errorf("expected a number after '%s' but got '%s'", token, tokens[i+1])
+			}
+			add, ok := expressionUnit(tokens[i+2])
+			if !ok {
+				return t, fmt.Errorf("unknown unit '%s'", tokens[i+2])
+			}
+			if token == "-" {
+				n = -n
+			}
+			t = add(t, n)
+			i += 2
+
+		default:
+			// n o'clock, optionally followed by am or pm
+			hour, err := strconv.Atoi(token)
+			if err != nil || i+1 >= len(tokens) || tokens[i+1] != "o'clock" {
+				return t, fmt.Errorf("unexpected '%s'", token)
+			}
+			i++
+			if i+1 < len(tokens) && (tokens[i+1] == "am" || tokens[i+1] == "pm") {
+				if hour < 1 || hour > 12 {
+					return t, fmt.Errorf("invalid hour %d %s", hour, tokens[i+1])
+				}
+				hour %= 12
+				if tokens[i+1] == "pm" {
+					hour += 12
+				}
+				i++
+			}
+			if hour > 23 {
+				return t, fmt.Errorf("invalid hour %d", hour)
+			}
+			t = atTime(t, hour)
+		}
+	}
+
+	return t, nil
+}
+
+var expressionTokens = regexp.MustCompile(`[+-]|\d+|o'clock|[a-z]+`)
+
+// expressionUnit finds the unit, which may be plural (e.g. days) or abbreviated as millis
+func expressionUnit(unit string) (func(t time.Time, n int) time.Time, bool) {
+	unit = strings.TrimSuffix(unit, "s")
+	if unit == "milli" {
+		unit = "millisecond"
+	}
+	add, ok := expressionUnits[unit]
+
+	return add, ok
+}
+
+// addMonths adds months to the time, keeping to the last day of the month if the day doesn't exist
+// in the resulting month (e.g. 31 January + 1 month is 28 February, where AddDate gives 3 March)
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location()).AddDate(0, n, 0)
+	lastDay := first.AddDate(0, 1, -1).Day()
+
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+
+	return first.AddDate(0, 0, day-1)
+}
+
+// atTime sets the time of day to the start of the hour
+func atTime(t time.Time, hour int) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), hour, 0, 0, 0, t.Location())
+}
+
+// formatWithPattern formats the time using a Java style date/time pattern
+func formatWithPattern(pattern string, t time.Time) (string, error) {
+	layout, err := goLayout(pattern)
+	if err != nil {
+		return "", err
+	}
+
+	return t.Format(layout), nil
+}
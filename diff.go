@@ -0,0 +1,264 @@
+package main
+
+// This file produces the human-readable diffs attached to mismatches (ContentMismatch.diff),
+// which the driver displays along with the mismatch. Text is diffed line by line in the
+// unified diff format, and binary content is diffed as a hex dump.
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Number of unchanged lines shown around each change
+const diffContext = 3
+
+// Limit on the size of the table used to find the longest common subsequence of lines.
+// Beyond this the changed lines are shown as removed and added as a whole
+const maxDiffCells = 4000000
+
+// Number of bytes in each line of a hex dump
+const hexDumpWidth = 16
+
+type diffLine struct {
+	kind byte // ' ' for unchanged lines, '-' for removed lines and '+' for added lines
+	text string
+}
+
+// diff returns the diff between the expected and actual values of a mismatch, or
+// an empty string if either value is missing. Binary contents are diffed with hexDiff
+func (c *comparison) diff(expected interface{}, actual interface{}) string {
+	if expected == nil || actual == nil {
+		return ""
+	}
+
+	return unifiedDiff(diffText(expected), diffText(actual))
+}
+
+// diffText formats a value for a text diff. Objects and arrays are indented so
+// that each value is on its own line
+func diffText(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(b)
+}
+
+// unifiedDiff returns a line-oriented diff of the expected and actual text in the unified diff format
+func unifiedDiff(expected string, actual string) string {
+	if expected == actual {
+		return ""
+	}
+
+	return formatUnified(diffLines(splitLines(expected), splitLines(actual)))
+}
+
+// hexDiff returns a unified diff of the hex dumps of the expected and actual bytes
+func hexDiff(expected []byte, actual []byte) string {
+	if string(expected) == string(actual) {
+		return ""
+	}
+
+	return formatUnified(diffLines(hexDump(expected), hexDump(actual)))
+}
+
+// hexDump formats the bytes in the style of hexdump -C, e.g.
+//
+//	00000000  7b 22 69 64 22 3a 20 31  7d                       |{"id": 1}|
+func hexDump(data []byte) []string {
+	var lines []string
+
+	for offset := 0; offset < len(data); offset += hexDumpWidth {
+		end := offset + hexDumpWidth
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := data[offset:end]
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "%08x ", offset)
+		for i := 0; i < hexDumpWidth; i++ {
+			if i == hexDumpWidth/2 {
+				b.WriteByte(' ')
+			}
+			if i < len(chunk) {
+				fmt.Fprintf(&b, " %02x", chunk[i])
+			} else {
+				b.WriteString("   ")
+			}
+		}
+		b.WriteString("  |")
+		for _, c := range chunk {
+			if c >= 0x20 && c < 0x7f {
+				b.WriteByte(c)
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('|')
+
+		lines = append(lines, b.String())
+	}
+
+	return lines
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines works out the lines removed from a and added in b, using the
+// longest common subsequence of the lines that differ
+func diffLines(a []string, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var lines []diffLine
+	for _, l := range a[:prefix] {
+		lines = append(lines, diffLine{' ', l})
+	}
+	lines = append(lines, diffChanged(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, l := range a[len(a)-suffix:] {
+		lines = append(lines, diffLine{' ', l})
+	}
+
+	return lines
+}
+
+// diffChanged diffs the lines between the common prefix and suffix
+func diffChanged(a []string, b []string) []diffLine {
+	var lines []diffLine
+
+	if len(a)*len(b) > maxDiffCells {
+		for _, l := range a {
+			lines = append(lines, diffLine{'-', l})
+		}
+		for _, l := range b {
+			lines = append(lines, diffLine{'+', l})
+		}
+		return lines
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+
+	return lines
+}
+
+// formatUnified formats the diff as hunks of changes with diffContext lines of context
+func formatUnified(lines []diffLine) string {
+	// Line numbers in the expected and actual text at the start of each diff line
+	expectedLine := make([]int, len(lines)+1)
+	actualLine := make([]int, len(lines)+1)
+	for k, l := range lines {
+		expectedLine[k+1] = expectedLine[k]
+		actualLine[k+1] = actualLine[k]
+		if l.kind != '+' {
+			expectedLine[k+1]++
+		}
+		if l.kind != '-' {
+			actualLine[k+1]++
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("--- expected\n+++ actual\n")
+
+	for k := 0; k < len(lines); {
+		for k < len(lines) && lines[k].kind == ' ' {
+			k++
+		}
+		if k == len(lines) {
+			break
+		}
+
+		// Changes separated by less than twice the context are shown in the same hunk
+		lastChange := k
+		for n := k; n < len(lines) && n-lastChange <= 2*diffContext; n++ {
+			if lines[n].kind != ' ' {
+				lastChange = n
+			}
+		}
+
+		start := k - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := lastChange + diffContext + 1
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n",
+			hunkRange(expectedLine[start], expectedLine[end]-expectedLine[start]),
+			hunkRange(actualLine[start], actualLine[end]-actualLine[start]))
+		for _, l := range lines[start:end] {
+			b.WriteByte(l.kind)
+			b.WriteString(l.text)
+			b.WriteByte('\n')
+		}
+
+		k = end
+	}
+
+	return b.String()
+}
+
+// hunkRange formats the start line and number of lines of a hunk. Line numbers start at 1,
+// except for an empty range, which refers to the line before it
+func hunkRange(start int, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
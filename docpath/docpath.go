@@ -0,0 +1,377 @@
+// Package docpath parses and resolves the path expressions used by Pact to refer to
+// parts of a document, e.g. matching rule keys and mismatch paths.
+//
+// Expressions follow a JSONPath-like syntax:
+//
+//	$                  the root of the document
+//	$.foo.bar          the "bar" field of the "foo" field
+//	$['a.b']           a field whose name needs quoting
+//	$.items[2]         the third item of the "items" array
+//	$.items[*].id      the "id" field of every item of the "items" array
+//	$.*                every field of the root object
+//
+// The values in a document are located by concrete paths (see Concrete), which have no wildcards
+// and record whether each fragment is an object key or an array index.
+//
+// When several expressions apply to a path, the one with the greatest weight is the most
+// specific, mirroring the rules of the Pact reference implementation.
+package docpath
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TokenType identifies the kind of a path token
+type TokenType int
+
+const (
+	// Root is the root of the document, "$"
+	Root TokenType = iota
+	// Field is a named field, ".name" or "['name']"
+	Field
+	// Index is an array index, "[n]"
+	Index
+	// Star matches any field, ".*"
+	Star
+	// StarIndex matches any array index, "[*]"
+	StarIndex
+)
+
+// Token is a single element of a path expression
+type Token struct {
+	Type  TokenType
+	Name  string // For Field tokens
+	Index int    // For Index tokens
+}
+
+// Fragment is a single element of a concrete path: the root, the key of an object field or the
+// index of an array item
+type Fragment struct {
+	Name    string // The field name, or "$" for the root
+	Index   int    // The item index, if IsIndex is set
+	IsIndex bool
+}
+
+// Concrete is the path of a value in a document, e.g. $.items[0].id. As each fragment records
+// whether it is a key or an index, the object key "1" is never mistaken for the array index 1
+type Concrete []Fragment
+
+// RootPath returns the concrete path of the root of a document
+func RootPath() Concrete {
+	return Concrete{{Name: "$"}}
+}
+
+// Field returns the path of a field of the object at this path
+func (c Concrete) Field(name string) Concrete {
+	return append(append(Concrete{}, c...), Fragment{Name: name})
+}
+
+// Item returns the path of an item of the array at this path
+func (c Concrete) Item(index int) Concrete {
+	return append(append(Concrete{}, c...), Fragment{Index: index, IsIndex: true})
+}
+
+// String formats the path as an expression (see Format)
+func (c Concrete) String() string {
+	return Format(c)
+}
+
+// Path is a parsed path expression
+type Path struct {
+	expression string
+	tokens     []Token
+}
+
+// Characters allowed in a field name without the bracket notation
+var identifierRegex = regexp.MustCompile(`^[a-zA-Z0-9_\-:#@]+$`)
+
+// Parse parses a path expression
+func Parse(expression string) (Path, error) {
+	p := parser{input: []rune(expression)}
+	tokens, err := p.parse()
+	if err != nil {
+		return Path{}, fmt.Errorf("invalid path expression '%s': %w", expression, err)
+	}
+
+	return Path{expression: expression, tokens: tokens}, nil
+}
+
+// String returns the expression the path was parsed from
+func (p Path) String() string {
+	return p.expression
+}
+
+// Tokens returns the tokens of the path
+func (p Path) Tokens() []Token {
+	return p.tokens
+}
+
+// Len is the number of tokens in the path, including the root
+func (p Path) Len() int {
+	return len(p.tokens)
+}
+
+// Weight calculates how well the expression matches a concrete path (e.g. $.items[0].id).
+// A weight of 0 means it doesn't match. The expression matches the path if it matches the path
+// itself or one of its parents. Exact names and indices weigh more than wildcards.
+func (p Path) Weight(path Concrete) int {
+	if len(path) < len(p.tokens) {
+		return 0
+	}
+
+	weight := 1
+	for i, token := range p.tokens {
+		weight *= tokenWeight(token, path[i])
+	}
+
+	return weight
+}
+
+func tokenWeight(token Token, fragment Fragment) int {
+	switch token.Type {
+	case Root:
+		if !fragment.IsIndex && fragment.Name == "$" {
+			return 2
+		}
+	case Field:
+		if !fragment.IsIndex && fragment.Name == token.Name {
+			return 2
+		}
+	case Index:
+		if fragment.IsIndex && fragment.Index == token.Index {
+			return 2
+		}
+	case StarIndex:
+		if fragment.IsIndex {
+			return 1
+		}
+	case Star:
+		return 1
+	}
+
+	return 0
+}
+
+// Matches reports if the expression matches the path or one of its parents
+func (p Path) Matches(path Concrete) bool {
+	return p.Weight(path) > 0
+}
+
+// MatchesExactly reports if the expression matches the path itself
+func (p Path) MatchesExactly(path Concrete) bool {
+	return len(path) == len(p.tokens) && p.Weight(path) > 0
+}
+
+// Resolve returns the concrete paths of the values in the document the expression refers to.
+// The document is a tree of map[string]interface{} and []interface{} values, as produced by
+// encoding/json.
+func (p Path) Resolve(document interface{}) []Concrete {
+	if len(p.tokens) == 0 || p.tokens[0].Type != Root {
+		return nil
+	}
+
+	return resolve(p.tokens[1:], RootPath(), document)
+}
+
+func resolve(tokens []Token, path Concrete, value interface{}) []Concrete {
+	if len(tokens) == 0 {
+		return []Concrete{path}
+	}
+
+	var paths []Concrete
+	token := tokens[0]
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		switch token.Type {
+		case Field:
+			if c, ok := v[token.Name]; ok {
+				paths = append(paths, resolve(tokens[1:], path.Field(token.Name), c)...)
+			}
+		case Star:
+			for _, key := range sortedKeys(v) {
+				paths = append(paths, resolve(tokens[1:], path.Field(key), v[key])...)
+			}
+		}
+
+	case []interface{}:
+		switch token.Type {
+		case Index:
+			if token.Index >= 0 && token.Index < len(v) {
+				paths = append(paths, resolve(tokens[1:], path.Item(token.Index), v[token.Index])...)
+			}
+		case StarIndex, Star:
+			for i, c := range v {
+				paths = append(paths, resolve(tokens[1:], path.Item(i), c)...)
+			}
+		}
+	}
+
+	return paths
+}
+
+// Format renders a concrete path as an expression, e.g. $.items[0].id. Only array items are
+// formatted as indices: a field whose name is a number is quoted, e.g. $['1']
+func Format(path Concrete) string {
+	var b strings.Builder
+
+	for i, fragment := range path {
+		switch {
+		case i == 0:
+			b.WriteString(fragment.Name)
+		case fragment.IsIndex:
+			b.WriteString("[" + strconv.Itoa(fragment.Index) + "]")
+		case identifierRegex.MatchString(fragment.Name) && !isNumber(fragment.Name):
+			b.WriteString("." + fragment.Name)
+		default:
+			b.WriteString("['" + strings.ReplaceAll(strings.ReplaceAll(fragment.Name, "\\", "\\\\"), "'", "\\'") + "']")
+		}
+	}
+
+	return b.String()
+}
+
+func isNumber(name string) bool {
+	_, err := strconv.Atoi(name)
+	return err == nil
+}
+
+// parser is a simple recursive descent parser for path expressions
+type parser struct {
+	input []rune
+	pos   int
+}
+
+func (p *parser) parse() ([]Token, error) {
+	if len(p.input) == 0 {
+		return nil, fmt.Errorf("expression is empty")
+	}
+	if p.input[0] != '$' {
+		return nil, fmt.Errorf("expression must start with '$'")
+	}
+	p.pos++
+
+	tokens := []Token{{Type: Root}}
+	for p.pos < len(p.input) {
+		var token Token
+		var err error
+
+		switch p.input[p.pos] {
+		case '.':
+			p.pos++
+			token, err = p.parseField()
+		case '[':
+			p.pos++
+			token, err = p.parseBracket()
+		default:
+			err = fmt.Errorf("expected '.' or '[' at position %d but found '%c'", p.pos, p.input[p.pos])
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return tokens, nil
+}
+
+// parseField parses a field name or star after a '.'
+func (p *parser) parseField() (Token, error) {
+	if p.pos < len(p.input) && p.input[p.pos] == '*' {
+		p.pos++
+		return Token{Type: Star}, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.input) && p.input[p.pos] != '.' && p.input[p.pos] != '[' {
+		p.pos++
+	}
+
+	name := string(p.input[start:p.pos])
+	if !identifierRegex.MatchString(name) {
+		return Token{}, fmt.Errorf("invalid field name '%s' at position %d", name, start)
+	}
+
+	return Token{Type: Field, Name: name}, nil
+}
+
+// parseBracket parses an index, star or quoted field name after a '['
+func (p *parser) parseBracket() (Token, error) {
+	if p.pos >= len(p.input) {
+		return Token{}, fmt.Errorf("unterminated '['")
+	}
+
+	var token Token
+	switch c := p.input[p.pos]; {
+	case c == '*':
+		p.pos++
+		token = Token{Type: StarIndex}
+
+	case c == '\'' || c == '"':
+		name, err := p.parseQuoted(c)
+		if err != nil {
+			return Token{}, err
+		}
+		token = Token{Type: Field, Name: name}
+
+	case c >= '0' && c <= '9':
+		start := p.pos
+		for p.pos < len(p.input) && p.input[p.pos] >= '0' && p.input[p.pos] <= '9' {
+			p.pos++
+		}
+		index, err := strconv.Atoi(string(p.input[start:p.pos]))
+		if err != nil {
+			return Token{}, err
+		}
+		token = Token{Type: Index, Index: index}
+
+	default:
+		return Token{}, fmt.Errorf("expected an index, '*' or a quoted field name at position %d but found '%c'", p.pos, c)
+	}
+
+	if p.pos >= len(p.input) || p.input[p.pos] != ']' {
+		return Token{}, fmt.Errorf("expected ']' at position %d", p.pos)
+	}
+	p.pos++
+
+	return token, nil
+}
+
+// parseQuoted parses a quoted string, where a backslash escapes the next character
+func (p *parser) parseQuoted(quote rune) (string, error) {
+	start := p.pos
+	p.pos++
+
+	var b strings.Builder
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		switch {
+		case c == '\\' && p.pos+1 < len(p.input):
+			b.WriteRune(p.input[p.pos+1])
+			p.pos += 2
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		default:
+			b.WriteRune(c)
+			p.pos++
+		}
+	}
+
+	return "", fmt.Errorf("unterminated quoted field name starting at position %d", start)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
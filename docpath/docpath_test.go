@@ -0,0 +1,207 @@
+package docpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expression string
+		tokens     []Token
+	}{
+		{"$", []Token{{Type: Root}}},
+		{"$.foo.bar", []Token{{Type: Root}, {Type: Field, Name: "foo"}, {Type: Field, Name: "bar"}}},
+		{"$.items[2]", []Token{{Type: Root}, {Type: Field, Name: "items"}, {Type: Index, Index: 2}}},
+		{"$.items[*].id", []Token{{Type: Root}, {Type: Field, Name: "items"}, {Type: StarIndex}, {Type: Field, Name: "id"}}},
+		{"$.*", []Token{{Type: Root}, {Type: Star}}},
+		{"$.a-b_c:d#e@f", []Token{{Type: Root}, {Type: Field, Name: "a-b_c:d#e@f"}}},
+		{"$.1", []Token{{Type: Root}, {Type: Field, Name: "1"}}},
+		{"$['a.b']", []Token{{Type: Root}, {Type: Field, Name: "a.b"}}},
+		{`$["a b"]`, []Token{{Type: Root}, {Type: Field, Name: "a b"}}},
+		{"$['1']", []Token{{Type: Root}, {Type: Field, Name: "1"}}},
+		{`$['it\'s']`, []Token{{Type: Root}, {Type: Field, Name: "it's"}}},
+		{`$['back\\slash']`, []Token{{Type: Root}, {Type: Field, Name: `back\slash`}}},
+		{`$["say \"hi\""]`, []Token{{Type: Root}, {Type: Field, Name: `say "hi"`}}},
+		{"$['']", []Token{{Type: Root}, {Type: Field, Name: ""}}},
+		{"$['ünïcödé']", []Token{{Type: Root}, {Type: Field, Name: "ünïcödé"}}},
+		{"$[0][1]", []Token{{Type: Root}, {Type: Index, Index: 0}, {Type: Index, Index: 1}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			path, err := Parse(test.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(path.Tokens(), test.tokens) {
+				t.Errorf("expected tokens %+v, got %+v", test.tokens, path.Tokens())
+			}
+			if path.String() != test.expression {
+				t.Errorf("expected the expression '%s', got '%s'", test.expression, path.String())
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"foo",
+		"$foo",
+		"$.",
+		"$..foo",
+		"$.foo bar",
+		"$[",
+		"$[1",
+		"$[]",
+		"$[-1]",
+		"$[a]",
+		"$['unterminated",
+		"$['a'",
+		"$['a'b]",
+		"$[*",
+		"$.foo.",
+		"$.a.b$",
+	}
+
+	for _, expression := range tests {
+		t.Run(expression, func(t *testing.T) {
+			if path, err := Parse(expression); err == nil {
+				t.Errorf("expected an error, got %+v", path.Tokens())
+			}
+		})
+	}
+}
+
+func TestWeight(t *testing.T) {
+	tests := []struct {
+		expression string
+		path       Concrete
+		weight     int
+	}{
+		{"$", RootPath(), 2},
+		{"$", RootPath().Field("a"), 2},
+		{"$.a", RootPath(), 0},
+		{"$.a", RootPath().Field("a"), 4},
+		{"$.a", RootPath().Field("b"), 0},
+		{"$.*", RootPath().Field("a"), 2},
+		{"$.*", RootPath().Item(0), 2},
+		{"$[0]", RootPath().Item(0), 4},
+		{"$[0]", RootPath().Item(1), 0},
+		{"$[*]", RootPath().Item(7), 2},
+		{"$.items[*].id", RootPath().Field("items").Item(3).Field("id"), 8},
+		{"$.items[3].id", RootPath().Field("items").Item(3).Field("id"), 16},
+		{"$.items[*]", RootPath().Field("items").Item(3).Field("id"), 4},
+
+		// Object keys that look like numbers are not array indices, and vice versa
+		{"$[1]", RootPath().Field("1"), 0},
+		{"$[*]", RootPath().Field("1"), 0},
+		{"$['1']", RootPath().Field("1"), 4},
+		{"$.1", RootPath().Item(1), 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression+" "+test.path.String(), func(t *testing.T) {
+			path, err := Parse(test.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w := path.Weight(test.path); w != test.weight {
+				t.Errorf("expected a weight of %d, got %d", test.weight, w)
+			}
+		})
+	}
+}
+
+func TestMatchesExactly(t *testing.T) {
+	path, err := Parse("$.items[*]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !path.MatchesExactly(RootPath().Field("items").Item(0)) {
+		t.Error("expected the expression to match $.items[0] exactly")
+	}
+	if path.MatchesExactly(RootPath().Field("items").Item(0).Field("id")) {
+		t.Error("expected the expression not to match $.items[0].id exactly")
+	}
+	if !path.Matches(RootPath().Field("items").Item(0).Field("id")) {
+		t.Error("expected the expression to match a child of $.items[0]")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	var document interface{}
+	err := json.Unmarshal([]byte(`{"items": [{"id": 1}, {"id": 2}], "1": "one", "a.b": true}`), &document)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		expression string
+		paths      []string
+	}{
+		{"$", []string{"$"}},
+		{"$.items[*].id", []string{"$.items[0].id", "$.items[1].id"}},
+		{"$.items[1]", []string{"$.items[1]"}},
+		{"$.items[2]", nil},
+		{"$.items.*", []string{"$.items[0]", "$.items[1]"}},
+		{"$.*", []string{"$['1']", "$['a.b']", "$.items"}},
+		{"$['1']", []string{"$['1']"}},
+		{"$[1]", nil},
+		{"$.missing", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			path, err := Parse(test.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var paths []string
+			for _, p := range path.Resolve(document) {
+				paths = append(paths, p.String())
+			}
+			if !reflect.DeepEqual(paths, test.paths) {
+				t.Errorf("expected %v, got %v", test.paths, paths)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		path       Concrete
+		expression string
+	}{
+		{RootPath(), "$"},
+		{RootPath().Field("foo").Field("bar"), "$.foo.bar"},
+		{RootPath().Field("items").Item(0).Field("id"), "$.items[0].id"},
+		{RootPath().Field("1"), "$['1']"},
+		{RootPath().Item(1), "$[1]"},
+		{RootPath().Field("a.b"), "$['a.b']"},
+		{RootPath().Field("it's"), `$['it\'s']`},
+		{RootPath().Field(`back\slash`), `$['back\\slash']`},
+		{RootPath().Field(""), "$['']"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			if e := Format(test.path); e != test.expression {
+				t.Fatalf("expected '%s', got '%s'", test.expression, e)
+			}
+
+			// The expression parses back into a path that matches only the formatted one
+			path, err := Parse(test.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !path.MatchesExactly(test.path) {
+				t.Errorf("expected '%s' to match the path it was formatted from", test.expression)
+			}
+		})
+	}
+}
@@ -0,0 +1,599 @@
+package main
+
+// This file contains the parser for the matching expressions that can be used in place of values in
+// the contents configured for an interaction, e.g. matching(type, 'Fred') or eachValue(matching(integer, 1)).
+// Each expression gives the example value to use in the contents, and the matching rules and generators
+// to apply at its path.
+// See https://github.com/pact-foundation/pact-plugins/blob/main/docs/matching-rule-definition-expressions.md
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/pact-foundation/pact-plugin-template-golang/docpath"
+	plugin "github.com/pact-foundation/pact-plugin-template-golang/io_pact_plugin"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// A string is treated as an expression if it starts with one of the expression functions
+var expressionRegex = regexp.MustCompile(`^\s*(matching|notEmpty|eachKey|eachValue|atLeast|atMost|fromProviderState)\s*\(`)
+
+// Upper bound on atLeast, as the example for a collection is repeated to meet it
+const maxExampleItems = 1000
+
+// matchingDefinition is the result of parsing an expression
+type matchingDefinition struct {
+	value    interface{}
+	hasValue bool
+
+	// Matcher definitions as they appear in a pact file, e.g. {"match": "regex", "regex": "\\d+"}
+	rules     []map[string]interface{}
+	generator *plugin.Generator
+
+	// Definitions for the keys and values of a collection
+	eachKey   *matchingDefinition
+	eachValue *matchingDefinition
+
+	// Bounds on the length of a collection, from atLeast and atMost
+	min, max int
+}
+
+// matchingExample is a definition resolved into the example value, the matcher definitions for
+// its path and the generators keyed by the path expression relative to it ("" being the path itself)
+type matchingExample struct {
+	value      interface{}
+	rules      []map[string]interface{}
+	generators map[string]*plugin.Generator
+}
+
+func isMatchingExpression(s string) bool {
+	return expressionRegex.MatchString(s)
+}
+
+// parseMatchingExpression parses an expression, or several separated by commas (e.g. atLeast(1), eachValue(...))
+func parseMatchingExpression(expression string) (*matchingDefinition, error) {
+	p := &expressionParser{input: []rune(expression)}
+
+	definition, err := p.expressions()
+	if err == nil && !p.done() {
+		err = p.errorf("unexpected '%c'", p.input[p.pos])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid matching expression '%s': %s", expression, err)
+	}
+
+	return definition, nil
+}
+
+// example resolves a definition into its example value, rules and generators. Collections get an
+// example from their eachValue (and eachKey) definitions, repeated to meet the atLeast bound
+func (d *matchingDefinition) example() (*matchingExample, error) {
+	e := &matchingExample{
+		value:      d.value,
+		rules:      d.rules,
+		generators: make(map[string]*plugin.Generator),
+	}
+	if d.generator != nil {
+		e.generators[""] = d.generator
+	}
+
+	if d.min > maxExampleItems {
+		return nil, fmt.Errorf("atLeast(%d) is greater than the maximum of %d", d.min, maxExampleItems)
+	}
+	if d.min > 0 {
+		e.rules = append(e.rules, map[string]interface{}{"match": "type", "min": d.min})
+	}
+	if d.max >= 0 {
+		if d.max < d.min {
+			return nil, fmt.Errorf("atLeast(%d) is greater than atMost(%d)", d.min, d.max)
+		}
+		e.rules = append(e.rules, map[string]interface{}{"match": "type", "max": d.max})
+	}
+
+	switch {
+	case d.eachKey != nil:
+		if d.eachValue == nil {
+			return nil, fmt.Errorf("eachKey must be combined with eachValue to give an example value")
+		}
+		keys, err := d.eachKey.example()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := keys.value.(string)
+		if !ok {
+			return nil, fmt.Errorf("eachKey needs a string example value, got %s", displayValue(keys.value))
+		}
+		values, err := d.eachValue.example()
+		if err != nil {
+			return nil, err
+		}
+
+		e.value = map[string]interface{}{key: values.value}
+		e.rules = append(e.rules,
+			map[string]interface{}{"match": "eachKey", "rules": definitionList(keys.rules)},
+			map[string]interface{}{"match": "eachValue", "rules": definitionList(values.rules)})
+		for expression, generator := range values.generators {
+			e.generators[".*"+expression] = generator
+		}
+
+	case d.eachValue != nil:
+		values, err := d.eachValue.example()
+		if err != nil {
+			return nil, err
+		}
+
+		items := make([]interface{}, 0, d.min)
+		for len(items) == 0 || len(items) < d.min {
+			items = append(items, values.value)
+		}
+		e.value = items
+		e.rules = append(e.rules, map[string]interface{}{"match": "eachValue", "rules": definitionList(values.rules)})
+		for expression, generator := range values.generators {
+			e.generators["[*]"+expression] = generator
+		}
+
+	case !d.hasValue:
+		return nil, fmt.Errorf("the expression does not give an example value")
+	}
+
+	// Check the example satisfies its own rules, to catch mistakes such as a regex that doesn't match
+	for _, definition := range e.rules {
+		if definition["match"] == "eachKey" || definition["match"] == "eachValue" {
+			continue
+		}
+		rule, err := matchingRuleFromDefinition(definition)
+		if err != nil {
+			return nil, err
+		}
+		if err := applyRule(rule, e.value, e.value); err != nil {
+			return nil, fmt.Errorf("the example value does not match: %s", err)
+		}
+	}
+
+	return e, nil
+}
+
+// definitionList converts matcher definitions into the list form used for the rules of eachKey and eachValue
+func definitionList(definitions []map[string]interface{}) []interface{} {
+	list := make([]interface{}, len(definitions))
+	for i, d := range definitions {
+		list[i] = d
+	}
+
+	return list
+}
+
+// expandExpressions replaces the expressions in a document with their example values, adding their
+// matching rules and generators at the path of each one
+func expandExpressions(path docpath.Concrete, value interface{}, rules map[string]*plugin.MatchingRules, generators map[string]*plugin.Generator) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		expanded := make(map[string]interface{}, len(v))
+		for _, key := range sortedKeys(v) {
+			e, err := expandExpressions(path.Field(key), v[key], rules, generators)
+			if err != nil {
+				return nil, err
+			}
+			expanded[key] = e
+		}
+		return expanded, nil
+
+	case []interface{}:
+		expanded := make([]interface{}, len(v))
+		for i := range v {
+			e, err := expandExpressions(path.Item(i), v[i], rules, generators)
+			if err != nil {
+				return nil, err
+			}
+			expanded[i] = e
+		}
+		return expanded, nil
+
+	case string:
+		if !isMatchingExpression(v) {
+			return v, nil
+		}
+
+		expression := docpath.Format(path)
+		definition, err := parseMatchingExpression(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", expression, err)
+		}
+		example, err := definition.example()
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid matching expression '%s': %s", expression, v, err)
+		}
+
+		if len(example.rules) > 0 {
+			list := &plugin.MatchingRules{}
+			for _, d := range example.rules {
+				rule, err := matchingRuleFromDefinition(d)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %s", expression, err)
+				}
+				list.Rule = append(list.Rule, rule)
+			}
+			rules[expression] = list
+		}
+		for suffix, generator := range example.generators {
+			generators[expression+suffix] = generator
+		}
+
+		return example.value, nil
+	}
+
+	return value, nil
+}
+
+// expressionParser is a simple recursive descent parser for matching expressions
+type expressionParser struct {
+	input []rune
+	pos   int
+}
+
+// expressions parses one or more expressions separated by commas, combining them into one definition
+func (p *expressionParser) expressions() (*matchingDefinition, error) {
+	combined := &matchingDefinition{max: -1}
+
+	for {
+		if err := p.expression(combined); err != nil {
+			return nil, err
+		}
+		if !p.accept(',') {
+			return combined, nil
+		}
+	}
+}
+
+// expression parses a single expression into the definition
+func (p *expressionParser) expression(d *matchingDefinition) error {
+	start := p.pos
+	name := p.identifier()
+	if name == "" {
+		return p.errorf("expected an expression")
+	}
+	if err := p.expect('('); err != nil {
+		return err
+	}
+
+	switch name {
+	case "matching":
+		if err := p.matching(d); err != nil {
+			return err
+		}
+
+	case "notEmpty":
+		value, err := p.primitive()
+		if err != nil {
+			return err
+		}
+		d.setValue(value)
+		d.rules = append(d.rules, map[string]interface{}{"match": "notEmpty"})
+
+	case "eachKey", "eachValue":
+		inner, err := p.expressions()
+		if err != nil {
+			return err
+		}
+		if name == "eachKey" {
+			d.eachKey = inner
+		} else {
+			d.eachValue = inner
+		}
+
+	case "atLeast", "atMost":
+		n, err := p.integer()
+		if err != nil {
+			return err
+		}
+		if name == "atLeast" {
+			d.min = n
+		} else {
+			d.max = n
+		}
+
+	case "fromProviderState":
+		expression, err := p.string()
+		if err != nil {
+			return err
+		}
+		if err := p.expect(','); err != nil {
+			return err
+		}
+		value, err := p.primitive()
+		if err != nil {
+			return err
+		}
+		d.setValue(value)
+		d.generator = &plugin.Generator{
+			Type: "ProviderState",
+			Values: &structpb.Struct{Fields: map[string]*structpb.Value{
+				"expression": structpb.NewStringValue(expression),
+				"dataType":   structpb.NewStringValue(providerStateDataType(value)),
+			}},
+		}
+
+	default:
+		p.pos = start
+		return p.errorf("unknown function '%s'", name)
+	}
+
+	return p.expect(')')
+}
+
+// matching parses the arguments of matching(...), which start with the matcher type
+func (p *expressionParser) matching(d *matchingDefinition) error {
+	if p.peek() == '$' {
+		return p.errorf("references to other values are not supported")
+	}
+
+	start := p.pos
+	matcher := p.identifier()
+	if err := p.expect(','); err != nil {
+		return err
+	}
+
+	switch matcher {
+	case "equalTo", "type":
+		value, err := p.primitive()
+		if err != nil {
+			return err
+		}
+		d.setValue(value)
+		d.rules = append(d.rules, map[string]interface{}{"match": normaliseRuleType(matcher)})
+
+	case "number", "integer", "decimal":
+		value, err := p.number()
+		if err != nil {
+			return err
+		}
+		d.setValue(value)
+		d.rules = append(d.rules, map[string]interface{}{"match": matcher})
+
+	case "datetime", "date", "time", "timestamp":
+		format, value, err := p.stringPair()
+		if err != nil {
+			return err
+		}
+		d.setValue(value)
+		d.rules = append(d.rules, map[string]interface{}{"match": normaliseRuleType(matcher), "format": format})
+
+	case "regex":
+		regex, value, err := p.stringPair()
+		if err != nil {
+			return err
+		}
+		d.setValue(value)
+		d.rules = append(d.rules, map[string]interface{}{"match": "regex", "regex": regex})
+
+	case "contentType":
+		contentType, value, err := p.stringPair()
+		if err != nil {
+			return err
+		}
+		d.setValue(value)
+		d.rules = append(d.rules, map[string]interface{}{"match": "contentType", "value": contentType})
+
+	case "include", "semver":
+		value, err := p.string()
+		if err != nil {
+			return err
+		}
+		d.setValue(value)
+		if matcher == "include" {
+			d.rules = append(d.rules, map[string]interface{}{"match": "include", "value": value})
+		} else {
+			d.rules = append(d.rules, map[string]interface{}{"match": "semver"})
+		}
+
+	case "boolean":
+		value, err := p.primitive()
+		if err != nil {
+			return err
+		}
+		if _, ok := value.(bool); !ok {
+			return p.errorf("expected true or false")
+		}
+		d.setValue(value)
+		d.rules = append(d.rules, map[string]interface{}{"match": "boolean"})
+
+	default:
+		p.pos = start
+		return p.errorf("unknown matcher type '%s'", matcher)
+	}
+
+	return nil
+}
+
+// setValue sets the example value, the first expression to give one wins
+func (d *matchingDefinition) setValue(value interface{}) {
+	if !d.hasValue {
+		d.value = value
+		d.hasValue = true
+	}
+}
+
+// providerStateDataType returns the data type of the ProviderState generator for an example value,
+// so that the generated value has the same type
+func providerStateDataType(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return "STRING"
+	case bool:
+		return "BOOLEAN"
+	case json.Number:
+		if isWholeNumber(v, 0) {
+			return "INTEGER"
+		}
+		return "DECIMAL"
+	default:
+		return "RAW"
+	}
+}
+
+// stringPair parses two string arguments separated by a comma, e.g. the format and the value of a date
+func (p *expressionParser) stringPair() (string, string, error) {
+	first, err := p.string()
+	if err != nil {
+		return "", "", err
+	}
+	if err := p.expect(','); err != nil {
+		return "", "", err
+	}
+	second, err := p.string()
+
+	return first, second, err
+}
+
+// primitive parses a string, number, boolean or null
+func (p *expressionParser) primitive() (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '\'':
+		return p.string()
+	case c == '-' || unicode.IsDigit(c):
+		return p.number()
+	}
+
+	start := p.pos
+	switch p.identifier() {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	p.pos = start
+
+	return nil, p.errorf("expected a string, number, boolean or null")
+}
+
+// string parses a quoted string. A backslash escapes a quote or another backslash, and is kept
+// otherwise so that regexes can be written as they are, e.g. '\d+'
+func (p *expressionParser) string() (string, error) {
+	if err := p.expect('\''); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		p.pos++
+		switch {
+		case c == '\'':
+			return b.String(), nil
+		case c == '\\' && p.pos < len(p.input) && (p.input[p.pos] == '\'' || p.input[p.pos] == '\\'):
+			b.WriteRune(p.input[p.pos])
+			p.pos++
+		default:
+			b.WriteRune(c)
+		}
+	}
+
+	return "", p.errorf("unterminated string")
+}
+
+// number parses a JSON style number, keeping how it was written
+func (p *expressionParser) number() (json.Number, error) {
+	p.skipSpaces()
+	start := p.pos
+	for p.pos < len(p.input) && strings.ContainsRune("+-.eE0123456789", p.input[p.pos]) {
+		p.pos++
+	}
+
+	n := json.Number(string(p.input[start:p.pos]))
+	if _, err := n.Float64(); err != nil || start == p.pos {
+		p.pos = start
+		return "", p.errorf("expected a number")
+	}
+
+	return n, nil
+}
+
+func (p *expressionParser) integer() (int, error) {
+	start := p.pos
+	n, err := p.number()
+	if err != nil {
+		return 0, err
+	}
+	i, ok := integerValue(n)
+	if !ok || i < 0 {
+		p.pos = start
+		return 0, p.errorf("expected a positive integer")
+	}
+
+	return int(i), nil
+}
+
+func (p *expressionParser) identifier() string {
+	p.skipSpaces()
+	start := p.pos
+	for p.pos < len(p.input) && (unicode.IsLetter(p.input[p.pos]) || unicode.IsDigit(p.input[p.pos]) || p.input[p.pos] == '-') {
+		p.pos++
+	}
+
+	return string(p.input[start:p.pos])
+}
+
+func (p *expressionParser) accept(c rune) bool {
+	if p.peek() == c {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *expressionParser) expect(c rune) error {
+	if !p.accept(c) {
+		return p.errorf("expected '%c'", c)
+	}
+
+	return nil
+}
+
+// peek returns the next character that isn't a space, or 0 at the end of the input
+func (p *expressionParser) peek() rune {
+	p.skipSpaces()
+	if p.done() {
+		return 0
+	}
+
+	return p.input[p.pos]
+}
+
+func (p *expressionParser) skipSpaces() {
+	for p.pos < len(p.input) && unicode.IsSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *expressionParser) done() bool {
+	p.skipSpaces()
+	return p.pos >= len(p.input)
+}
+
+func (p *expressionParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s at position %d", fmt.Sprintf(format, args...), p.pos)
+}
+
+// marshalContents serialises the expanded contents of a part: strings are the contents as text,
+// anything else is encoded as JSON
+func marshalContents(value interface{}) ([]byte, error) {
+	if s, ok := value.(string); ok {
+		return []byte(s), nil
+	}
+
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseMatchingExpression(t *testing.T) {
+	tests := []struct {
+		expression string
+		value      interface{}
+		rules      []map[string]interface{}
+	}{
+		{
+			"matching(type, 'Fred')",
+			"Fred",
+			[]map[string]interface{}{{"match": "type"}},
+		},
+		{
+			"matching(equalTo, 42)",
+			json.Number("42"),
+			[]map[string]interface{}{{"match": "equality"}},
+		},
+		{
+			"  matching( integer ,  -7 )  ",
+			json.Number("-7"),
+			[]map[string]interface{}{{"match": "integer"}},
+		},
+		{
+			"matching(decimal, 1.5e3)",
+			json.Number("1.5e3"),
+			[]map[string]interface{}{{"match": "decimal"}},
+		},
+		{
+			"matching(boolean, true)",
+			true,
+			[]map[string]interface{}{{"match": "boolean"}},
+		},
+		{
+			"matching(type, null)",
+			nil,
+			[]map[string]interface{}{{"match": "type"}},
+		},
+		{
+			`matching(regex, '\d+', '123')`,
+			"123",
+			[]map[string]interface{}{{"match": "regex", "regex": `\d+`}},
+		},
+		{
+			`matching(regex, 'it\'s \\\\ \w+', 'it\'s \\ here')`,
+			`it's \ here`,
+			[]map[string]interface{}{{"match": "regex", "regex": `it's \\ \w+`}},
+		},
+		{
+			"matching(type, 'a, b (c)')",
+			"a, b (c)",
+			[]map[string]interface{}{{"match": "type"}},
+		},
+		{
+			"matching(type, 'ünïcödé')",
+			"ünïcödé",
+			[]map[string]interface{}{{"match": "type"}},
+		},
+		{
+			"matching(date, 'yyyy-MM-dd', '2022-01-02')",
+			"2022-01-02",
+			[]map[string]interface{}{{"match": "date", "format": "yyyy-MM-dd"}},
+		},
+		{
+			"matching(include, 'foo')",
+			"foo",
+			[]map[string]interface{}{{"match": "include", "value": "foo"}},
+		},
+		{
+			"notEmpty('x')",
+			"x",
+			[]map[string]interface{}{{"match": "notEmpty"}},
+		},
+		{
+			"eachValue(matching(type, 'admin'))",
+			[]interface{}{"admin"},
+			[]map[string]interface{}{{"match": "eachValue", "rules": []interface{}{map[string]interface{}{"match": "type"}}}},
+		},
+		{
+			"atLeast(3), eachValue(matching(integer, 1))",
+			[]interface{}{json.Number("1"), json.Number("1"), json.Number("1")},
+			[]map[string]interface{}{
+				{"match": "type", "min": 3},
+				{"match": "eachValue", "rules": []interface{}{map[string]interface{}{"match": "integer"}}},
+			},
+		},
+		{
+			"atLeast(1), atMost(2), eachValue(matching(type, 'a'))",
+			[]interface{}{"a"},
+			[]map[string]interface{}{
+				{"match": "type", "min": 1},
+				{"match": "type", "max": 2},
+				{"match": "eachValue", "rules": []interface{}{map[string]interface{}{"match": "type"}}},
+			},
+		},
+		{
+			"eachKey(matching(regex, '[a-z]+', 'key')), eachValue(matching(type, 1))",
+			map[string]interface{}{"key": json.Number("1")},
+			[]map[string]interface{}{
+				{"match": "eachKey", "rules": []interface{}{map[string]interface{}{"match": "regex", "regex": "[a-z]+"}}},
+				{"match": "eachValue", "rules": []interface{}{map[string]interface{}{"match": "type"}}},
+			},
+		},
+		{
+			"atMost(5), eachKey(matching(type, 'k')), eachValue(atLeast(2), eachValue(matching(type, 'v')))",
+			map[string]interface{}{"k": []interface{}{"v", "v"}},
+			[]map[string]interface{}{
+				{"match": "type", "max": 5},
+				{"match": "eachKey", "rules": []interface{}{map[string]interface{}{"match": "type"}}},
+				{"match": "eachValue", "rules": []interface{}{
+					map[string]interface{}{"match": "type", "min": 2},
+					map[string]interface{}{"match": "eachValue", "rules": []interface{}{map[string]interface{}{"match": "type"}}},
+				}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			definition, err := parseMatchingExpression(test.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			example, err := definition.example()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(example.value, test.value) {
+				t.Errorf("expected the value %#v, got %#v", test.value, example.value)
+			}
+			if !reflect.DeepEqual(example.rules, test.rules) {
+				t.Errorf("expected the rules %v, got %v", test.rules, example.rules)
+			}
+		})
+	}
+}
+
+func TestParseMatchingExpressionInvalid(t *testing.T) {
+	tests := []struct {
+		expression string
+		err        string
+	}{
+		{"matching(type, 'Fred'", "expected ')'"},
+		{"matching(type 'Fred')", "expected ','"},
+		{"matching(type, 'Fred)", "unterminated string"},
+		{"matching(unknown, 'Fred')", "unknown matcher type 'unknown'"},
+		{"matching($'other', 'Fred')", "references to other values are not supported"},
+		{"matching(integer, 'one')", "expected a number"},
+		{"matching(boolean, 1)", "expected true or false"},
+		{"matching(type, Fred)", "expected a string, number, boolean or null"},
+		{"matching(type, 'Fred') extra", "unexpected 'e'"},
+		{"unknown(1)", "unknown function 'unknown'"},
+		{"atLeast(-1), eachValue(matching(type, 1))", "expected a positive integer"},
+		{"atLeast(1.5), eachValue(matching(type, 1))", "expected a positive integer"},
+		{"eachValue(matching(type, 1)", "expected ')'"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			_, err := parseMatchingExpression(test.expression)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected the error to contain '%s', got '%s'", test.err, err)
+			}
+		})
+	}
+}
+
+func TestMatchingExampleInvalid(t *testing.T) {
+	tests := []struct {
+		expression string
+		err        string
+	}{
+		{"atLeast(100000000000), eachValue(matching(type, 1))", "atLeast(100000000000) is greater than the maximum"},
+		{"atLeast(3), atMost(2), eachValue(matching(type, 1))", "atLeast(3) is greater than atMost(2)"},
+		{"eachKey(matching(type, 'k'))", "eachKey must be combined with eachValue"},
+		{"eachKey(matching(type, 1)), eachValue(matching(type, 1))", "eachKey needs a string example value"},
+		{"atLeast(1)", "the expression does not give an example value"},
+		{`matching(regex, '\d+', 'abc')`, "the example value does not match"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			definition, err := parseMatchingExpression(test.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = definition.example()
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected the error to contain '%s', got '%s'", test.err, err)
+			}
+		})
+	}
+}
+
+// The rules and generators of the expressions in a body are keyed by the path of each expression
+func TestConfigurationBodyContents(t *testing.T) {
+	var body configurationBody
+	err := body.UnmarshalJSON([]byte(`{
+		"name": "matching(type, 'Fred')",
+		"plain": "not an expression",
+		"1": "matching(integer, 1)",
+		"a.b": "matching(boolean, false)",
+		"ids": "atLeast(2), eachValue(fromProviderState('${id}', 1))",
+		"scores": "eachKey(matching(type, 'maths')), eachValue(fromProviderState('${score}', 10))",
+		"items": [{"id": "matching(integer, 7)"}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, rules, generators, err := body.contents()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"1":1,"a.b":false,"ids":[1,1],"items":[{"id":7}],"name":"Fred","plain":"not an expression","scores":{"maths":10}}`
+	if string(content) != expected {
+		t.Errorf("expected the contents %s, got %s", expected, content)
+	}
+
+	expectedRules := []string{"$.ids", "$.items[0].id", "$.name", "$.scores", "$['1']", "$['a.b']"}
+	if keys := sortedKeys(rules); !reflect.DeepEqual(keys, expectedRules) {
+		t.Errorf("expected rules for %v, got %v", expectedRules, keys)
+	}
+
+	expectedGenerators := []string{"$.ids[*]", "$.scores.*"}
+	if keys := sortedKeys(generators); !reflect.DeepEqual(keys, expectedGenerators) {
+		t.Errorf("expected generators for %v, got %v", expectedGenerators, keys)
+	}
+	for _, key := range expectedGenerators {
+		if g := generators[key]; g != nil && g.Type != "ProviderState" {
+			t.Errorf("expected a ProviderState generator at %s, got %s", key, g.Type)
+		}
+	}
+
+	// The rule types at each path, sorted
+	types := make(map[string][]string)
+	for key, list := range rules {
+		for _, rule := range list.Rule {
+			types[key] = append(types[key], rule.Type)
+		}
+	}
+	for key := range types {
+		sort.Strings(types[key])
+	}
+	if want := []string{"eachValue", "type"}; !reflect.DeepEqual(types["$.ids"], want) {
+		t.Errorf("expected the rules %v at $.ids, got %v", want, types["$.ids"])
+	}
+	if want := []string{"eachKey", "eachValue"}; !reflect.DeepEqual(types["$.scores"], want) {
+		t.Errorf("expected the rules %v at $.scores, got %v", want, types["$.scores"])
+	}
+}
+
+func TestConfigurationBodyContentsInvalid(t *testing.T) {
+	var body configurationBody
+	err := body.UnmarshalJSON([]byte(`{"items": ["matching(integer, 'one')"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, _, err = body.contents()
+	if err == nil || !strings.HasPrefix(err.Error(), "$.items[0]: ") {
+		t.Errorf("expected an error for $.items[0], got %v", err)
+	}
+}
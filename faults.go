@@ -0,0 +1,161 @@
+package main
+
+// This file contains the transport behaviours (delays and faults) the mock server
+// can apply when responding, so that consumers can test their retry and timeout logic
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Faults the mock server can inject when sending the response
+const (
+	faultNone     = ""
+	faultClose    = "close"    // Close the connection part way through the response frame
+	faultReset    = "reset"    // Reset the connection (TCP RST) instead of responding
+	faultTruncate = "truncate" // Send a well-formed frame containing only half of the payload
+)
+
+// transportBehaviour describes how the mock server should behave when responding to an interaction.
+// It is declared by the consumer as "transport" in the response configuration, e.g.
+//
+//	{"response": {"body": "tcpworld", "transport": {"delay": "50ms-200ms", "fault": "reset"}}}
+type transportBehaviour struct {
+	// Fixed delay before responding e.g. "100ms", or a random delay in a range e.g. "50ms-200ms"
+	Delay string
+
+	// One of "close", "reset" or "truncate"
+	Fault string
+}
+
+// validate checks the behaviour can be applied by the mock server
+func (b transportBehaviour) validate() error {
+	if _, _, err := b.delayRange(); err != nil {
+		return err
+	}
+
+	switch b.Fault {
+	case faultNone, faultClose, faultReset, faultTruncate:
+		return nil
+	default:
+		return fmt.Errorf("unknown transport fault '%s', expected one of '%s', '%s' or '%s'", b.Fault, faultClose, faultReset, faultTruncate)
+	}
+}
+
+// delayRange parses the delay into its lower and upper bounds. A fixed delay has equal bounds
+func (b transportBehaviour) delayRange() (time.Duration, time.Duration, error) {
+	if b.Delay == "" {
+		return 0, 0, nil
+	}
+
+	lower, upper, isRange := strings.Cut(b.Delay, "-")
+	min, err := time.ParseDuration(strings.TrimSpace(lower))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid transport delay '%s': %w", b.Delay, err)
+	}
+	if !isRange {
+		return min, min, nil
+	}
+
+	max, err := time.ParseDuration(strings.TrimSpace(upper))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid transport delay '%s': %w", b.Delay, err)
+	}
+	if max < min {
+		return 0, 0, fmt.Errorf("invalid transport delay '%s': the upper bound is less than the lower bound", b.Delay)
+	}
+
+	return min, max, nil
+}
+
+// delay returns how long to wait before responding, picking a random value if the delay is a range
+func (b transportBehaviour) delay() time.Duration {
+	min, max, err := b.delayRange()
+	if err != nil || max == 0 {
+		return 0
+	}
+	if max == min {
+		return min
+	}
+
+	return min + time.Duration(rand.Int63n(int64(max-min)+1))
+}
+
+// pluginConfiguration is the interaction configuration persisted to the pact file for the behaviour
+func (b transportBehaviour) pluginConfiguration() (*structpb.Struct, error) {
+	return structpb.NewStruct(map[string]interface{}{
+		"transport": map[string]interface{}{
+			"delay": b.Delay,
+			"fault": b.Fault,
+		},
+	})
+}
+
+// transportBehaviourFor reads the transport behaviour from the plugin configuration of an interaction.
+// The configuration is keyed by the plugin name, so every entry is checked for a "transport" value
+func transportBehaviourFor(i interaction) transportBehaviour {
+	for name, raw := range i.PluginConfiguration {
+		var config struct {
+			Transport *transportBehaviour
+		}
+		if err := json.Unmarshal(raw, &config); err != nil || config.Transport == nil {
+			continue
+		}
+		if err := config.Transport.validate(); err != nil {
+			log.Println("[WARN] ignoring invalid transport behaviour configured by plugin", name, ":", err)
+			continue
+		}
+
+		return *config.Transport
+	}
+
+	return transportBehaviour{}
+}
+
+// writeFaultyFrame sends the payload applying the fault. It returns the part of the payload that was
+// actually written (nil if nothing was), and false if the connection was closed as a result
+func writeFaultyFrame(conn net.Conn, payload []byte, fault string) ([]byte, bool, error) {
+	switch fault {
+	case faultReset:
+		resetConnection(conn)
+		return nil, false, nil
+
+	case faultClose:
+		written := payload[:len(payload)/2]
+		frame := make([]byte, 4+len(written))
+		binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+		copy(frame[4:], written)
+		_, err := conn.Write(frame)
+		conn.Close()
+		return written, false, err
+
+	case faultTruncate:
+		written := payload[:len(payload)/2]
+		return written, true, writeFrame(conn, written)
+
+	default:
+		return payload, true, writeFrame(conn, payload)
+	}
+}
+
+// resetConnection closes the connection with a TCP reset rather than a graceful close
+func resetConnection(conn net.Conn) {
+	raw := conn
+	if t, ok := conn.(*tls.Conn); ok {
+		raw = t.NetConn()
+	}
+	if tcp, ok := raw.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+
+	raw.Close()
+}
@@ -0,0 +1,616 @@
+package main
+
+// This file contains the generator engine used by GenerateContent. Generators replace the
+// values at their paths with fresh values each time the contents are generated, e.g. new
+// IDs and timestamps in the mock server responses.
+// See https://github.com/pact-foundation/pact-specification/tree/version-4#generators
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pact-foundation/pact-plugin-template-golang/docpath"
+	plugin "github.com/pact-foundation/pact-plugin-template-golang/io_pact_plugin"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Upper bound on the repetitions of unbounded regex operators (*, + and {n,}) by the Regex generator
+const maxRegexRepeat = 10
+
+// Characters used by the RandomString generator
+const randomStringCharacters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// generation holds the state for generating the contents of a body
+type generation struct {
+	// Generators, keyed by path expression
+	generators map[string]*plugin.Generator
+
+	rand *rand.Rand
+
+	// The time relative to which dates and times are generated
+	now time.Time
+
+	// Values from the test context used by the ProviderState and MockServerURL generators, e.g.
+	// {"providerState": {"id": 1234}, "mockServer": {"href": "tcp://127.0.0.1:1234"}}
+	context map[string]interface{}
+}
+
+// Key in the pact configuration, and environment variable (which takes precedence), used to seed
+// the generators so that they produce the same output on every run
+const (
+	generatorSeedKey    = "generatorSeed"
+	generatorSeedEnvVar = "GENERATOR_SEED"
+)
+
+// Key in the pact configuration, and environment variable (which takes precedence), used to fix the
+// time relative to which dates and times are generated (an RFC 3339 timestamp), so that they are
+// reproducible too. It is separate from the seed, which doesn't change the time
+const (
+	generatorTimeKey    = "generatorTime"
+	generatorTimeEnvVar = "GENERATOR_TIME"
+)
+
+// newGeneration creates the state for generating contents, seeded from the environment or otherwise
+// the seed configured in the pact (see generatorSeed). Without a seed, a random one is used. The seed
+// is logged either way, so that the random values of a run can be reproduced. Dates and times are
+// generated relative to the current time, unless a fixed time is given (see generatorTime)
+func newGeneration(generators map[string]*plugin.Generator, context map[string]interface{}, configuredSeed interface{}, configuredTime interface{}) *generation {
+	now := time.Now()
+	seed, seeded := generatorSeed(configuredSeed)
+	if !seeded {
+		seed = now.UnixNano()
+	}
+	if t, ok := generatorTime(configuredTime); ok {
+		now = t
+	}
+
+	if len(generators) > 0 {
+		log.Println("[INFO] generating contents with seed", seed)
+	}
+
+	return &generation{
+		generators: generators,
+		rand:       rand.New(rand.NewSource(seed)),
+		now:        now,
+		context:    context,
+	}
+}
+
+// generatorSeed returns the seed for the generators from the environment or otherwise the value
+// configured in the pact, and false if there isn't one
+func generatorSeed(configured interface{}) (int64, bool) {
+	if env := os.Getenv(generatorSeedEnvVar); env != "" {
+		if n, err := strconv.ParseInt(env, 10, 64); err == nil {
+			return n, true
+		}
+		log.Println("[WARN] ignoring invalid", generatorSeedEnvVar, "value:", env)
+	}
+
+	if configured == nil {
+		return 0, false
+	}
+	n, ok := integerValue(configured)
+	if !ok {
+		log.Println("[WARN] ignoring invalid", generatorSeedKey, "value:", configured)
+		return 0, false
+	}
+
+	return n, true
+}
+
+// generatorTime returns the fixed time for the generators from the environment or otherwise the value
+// configured in the pact, and false if there isn't one
+func generatorTime(configured interface{}) (time.Time, bool) {
+	if env := os.Getenv(generatorTimeEnvVar); env != "" {
+		if t, err := time.Parse(time.RFC3339, env); err == nil {
+			return t, true
+		}
+		log.Println("[WARN] ignoring invalid", generatorTimeEnvVar, "value:", env)
+	}
+
+	if configured == nil {
+		return time.Time{}, false
+	}
+	s, _ := configured.(string)
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		log.Println("[WARN] ignoring invalid", generatorTimeKey, "value:", configured)
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+// generationContext builds the context for generating contents from the plugin configuration.
+// The provider state parameters and mock server details are taken from the providerState and
+// mockServer keys, with the interaction configuration taking precedence over the pact configuration
+func generationContext(configuration *plugin.PluginConfiguration) map[string]interface{} {
+	context := make(map[string]interface{})
+	for _, c := range []map[string]interface{}{
+		configuration.GetPactConfiguration().AsMap(),
+		configuration.GetInteractionConfiguration().AsMap(),
+	} {
+		for _, key := range []string{"providerState", "mockServer"} {
+			if v, ok := c[key]; ok {
+				context[key] = v
+			}
+		}
+	}
+
+	return context
+}
+
+// pluginGenerators converts the generators for a category in a pact file into the form used by the plugin interface
+func pluginGenerators(g generators, category string) map[string]*plugin.Generator {
+	converted := make(map[string]*plugin.Generator)
+
+	for expression, definition := range g[category] {
+		t, _ := definition["type"].(string)
+		values := make(map[string]interface{})
+		for k, v := range definition {
+			if k != "type" {
+				values[k] = v
+			}
+		}
+
+		s, err := structpb.NewStruct(values)
+		if err != nil {
+			log.Println("[WARN] ignoring invalid generator at", expression, ":", err)
+			continue
+		}
+		converted[expression] = &plugin.Generator{Type: t, Values: s}
+	}
+
+	return converted
+}
+
+// generateBody applies the generators to the body. JSON contents have the values at the path of
+// each generator replaced, other contents are treated as a single string value at $. A generator
+// that fails is logged and skipped, leaving the original value in place.
+func (g *generation) generateBody(body []byte) []byte {
+	if len(g.generators) == 0 {
+		return body
+	}
+
+	document := parseContent(body)
+
+	for _, expression := range sortedKeys(g.generators) {
+		path, err := docpath.Parse(expression)
+		if err != nil {
+			log.Println("[WARN] ignoring generator:", err)
+			continue
+		}
+
+		for _, p := range path.Resolve(document) {
+			value, err := g.generate(g.generators[expression], valueAt(document, p))
+			if err != nil {
+				log.Println("[WARN] unable to generate the value at", docpath.Format(p), ":", err)
+				continue
+			}
+			document = setValue(document, p, value)
+		}
+	}
+
+	// Text contents (or contents replaced as a whole by a string) are returned as is
+	if s, ok := document.(string); ok {
+		return []byte(s)
+	}
+
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(document); err != nil {
+		log.Println("ERROR unable to serialise the generated contents:", err)
+		return body
+	}
+
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+}
+
+// generate creates a new value with the generator. The current value is given for generators
+// that need it
+func (g *generation) generate(generator *plugin.Generator, current interface{}) (interface{}, error) {
+	values := generator.GetValues().AsMap()
+
+	switch normaliseRuleType(generator.Type) {
+	case "randomint":
+		min, max := int64(0), int64(2147483647)
+		if v, ok := integerValue(values["min"]); ok {
+			min = v
+		}
+		if v, ok := integerValue(values["max"]); ok {
+			max = v
+		}
+		if max < min {
+			return nil, fmt.Errorf("RandomInt max %d is less than min %d", max, min)
+		}
+		return json.Number(strconv.FormatInt(min+g.rand.Int63n(max-min+1), 10)), nil
+
+	case "randomdecimal":
+		digits := intValue(values["digits"], 10)
+		if digits < 1 {
+			return nil, fmt.Errorf("RandomDecimal digits must be at least 1")
+		}
+		return json.Number(g.randomDecimal(digits)), nil
+
+	case "randomhexadecimal":
+		digits := intValue(values["digits"], 10)
+		if digits < 1 {
+			return nil, fmt.Errorf("RandomHexadecimal digits must be at least 1")
+		}
+		return g.randomFrom("0123456789abcdef", digits), nil
+
+	case "randomstring":
+		size := intValue(values["size"], 20)
+		if size < 1 {
+			return nil, fmt.Errorf("RandomString size must be at least 1")
+		}
+		return g.randomFrom(randomStringCharacters, size), nil
+
+	case "randomboolean":
+		return g.rand.Intn(2) == 1, nil
+
+	case "uuid":
+		return g.uuid(values["format"])
+
+	case "date":
+		return g.dateTime(values, defaultDateFormat)
+
+	case "time":
+		return g.dateTime(values, defaultTimeFormat)
+
+	case "datetime":
+		return g.dateTime(values, defaultDateTimeFormat)
+
+	case "regex":
+		regex, _ := values["regex"].(string)
+		return g.regex(regex)
+
+	case "providerstate":
+		return g.providerState(values)
+
+	case "mockserverurl":
+		return g.mockServerURL(values, current)
+
+	default:
+		return nil, fmt.Errorf("unsupported generator type '%s'", generator.Type)
+	}
+}
+
+// randomDecimal generates a decimal number with the given number of digits, e.g. 4 digits gives 12.34
+func (g *generation) randomDecimal(digits int) string {
+	if digits == 1 {
+		return strconv.Itoa(g.rand.Intn(10))
+	}
+
+	// Avoid a leading zero, as it wouldn't be a valid JSON number
+	n := g.randomFrom("123456789", 1) + g.randomFrom("0123456789", digits-1)
+	point := 1 + g.rand.Intn(digits-1)
+
+	return n[:point] + "." + n[point:]
+}
+
+func (g *generation) randomFrom(characters string, size int) string {
+	b := make([]byte, size)
+	for i := range b {
+		b[i] = characters[g.rand.Intn(len(characters))]
+	}
+
+	return string(b)
+}
+
+// uuid generates a random UUID in the format given: lower-case-hyphenated (the default),
+// upper-case-hyphenated, simple (without hyphens) or URN
+func (g *generation) uuid(format interface{}) (string, error) {
+	id, err := uuid.NewRandomFromReader(g.rand)
+	if err != nil {
+		return "", err
+	}
+
+	switch f, _ := format.(string); normaliseRuleType(f) {
+	case "", "lowercasehyphenated":
+		return id.String(), nil
+	case "uppercasehyphenated":
+		return strings.ToUpper(id.String()), nil
+	case "simple":
+		return strings.ReplaceAll(id.String(), "-", ""), nil
+	case "urn":
+		return id.URN(), nil
+	default:
+		return "", fmt.Errorf("unsupported UUID format '%v'", format)
+	}
+}
+
+// dateTime generates a date and/or time in the format given, relative to now or evaluated from
+// the expression given (see evaluateExpression)
+func (g *generation) dateTime(values map[string]interface{}, defaultFormat string) (string, error) {
+	format, _ := values["format"].(string)
+	if format == "" {
+		format = defaultFormat
+	}
+
+	t := g.now
+	if expression, _ := values["expression"].(string); expression != "" {
+		var err error
+		t, err = evaluateExpression(expression, g.now)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return formatWithPattern(format, t)
+}
+
+// providerState evaluates the expression with the parameters from the provider state. An expression
+// that is a single ${name} gives the value of the parameter itself, otherwise each ${name} is replaced
+// in the text. The result is converted to the dataType, if given.
+func (g *generation) providerState(values map[string]interface{}) (interface{}, error) {
+	expression, _ := values["expression"].(string)
+	params, ok := g.context["providerState"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("no provider state parameters are available for the expression '%s'", expression)
+	}
+
+	var value interface{}
+	if m := providerStateExpression.FindStringSubmatch(expression); m != nil && m[0] == expression {
+		v, ok := params[m[1]]
+		if !ok {
+			return nil, fmt.Errorf("the provider state has no parameter '%s'", m[1])
+		}
+		value = v
+	} else {
+		var missing []string
+		value = providerStateExpression.ReplaceAllStringFunc(expression, func(s string) string {
+			name := providerStateExpression.FindStringSubmatch(s)[1]
+			v, ok := params[name]
+			if !ok {
+				missing = append(missing, name)
+				return s
+			}
+			if p, ok := primitiveString(v); ok {
+				return p
+			}
+			return fmt.Sprint(v)
+		})
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("the provider state has no parameter(s) %s", strings.Join(missing, ", "))
+		}
+	}
+
+	dataType, _ := values["dataType"].(string)
+	return convertDataType(value, dataType)
+}
+
+// Provider state expressions, e.g. ${id}
+var providerStateExpression = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// convertDataType converts a generated value to the data type given by a generator
+func convertDataType(value interface{}, dataType string) (interface{}, error) {
+	switch strings.ToUpper(dataType) {
+	case "", "RAW":
+		return value, nil
+	case "STRING":
+		if s, ok := primitiveString(value); ok {
+			return s, nil
+		}
+		return fmt.Sprint(value), nil
+	case "INTEGER":
+		if n, ok := integerValue(value); ok {
+			return json.Number(strconv.FormatInt(n, 10)), nil
+		}
+	case "DECIMAL", "FLOAT":
+		if n, ok := numberValue(value); ok {
+			return json.Number(strconv.FormatFloat(n, 'f', -1, 64)), nil
+		}
+	case "BOOLEAN":
+		if s, ok := primitiveString(value); ok {
+			if b, err := strconv.ParseBool(s); err == nil {
+				return b, nil
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported data type '%s'", dataType)
+	}
+
+	return nil, fmt.Errorf("unable to convert %s to %s", displayValue(value), strings.ToUpper(dataType))
+}
+
+// mockServerURL replaces the base URL of the current value (or the example given) with the URL of the
+// running mock server. The regex must match the value, and its first group is the part that is kept,
+// e.g. the regex ".*(/orders/\d+)$" gives the mock server URL followed by /orders/1234
+func (g *generation) mockServerURL(values map[string]interface{}, current interface{}) (interface{}, error) {
+	mockServer, _ := g.context["mockServer"].(map[string]interface{})
+	href, _ := mockServer["href"].(string)
+	if href == "" {
+		href, _ = mockServer["url"].(string)
+	}
+	if href == "" {
+		return nil, fmt.Errorf("the mock server URL is not available")
+	}
+
+	example, ok := current.(string)
+	if !ok || example == "" {
+		example, _ = values["example"].(string)
+	}
+	regex, _ := values["regex"].(string)
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex '%s': %w", regex, err)
+	}
+
+	m := re.FindStringSubmatch(example)
+	if m == nil {
+		return nil, fmt.Errorf("the regex '%s' does not match '%s'", regex, example)
+	}
+	if len(m) < 2 {
+		return href, nil
+	}
+
+	return strings.TrimSuffix(href, "/") + m[1], nil
+}
+
+// regex generates a string matching the regular expression
+func (g *generation) regex(regex string) (string, error) {
+	re, err := syntax.Parse(regex, syntax.Perl)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex '%s': %w", regex, err)
+	}
+
+	var b strings.Builder
+	g.generateRegex(&b, re.Simplify())
+
+	generated := b.String()
+	if matched, err := regexp.MatchString(regex, generated); err != nil || !matched {
+		return "", fmt.Errorf("unable to generate a value matching the regex '%s' (got '%s')", regex, generated)
+	}
+
+	return generated, nil
+}
+
+// printableRange intersects a range of characters with printable ASCII. The range is empty if lo > hi
+func printableRange(lo rune, hi rune) (rune, rune) {
+	if lo < ' ' {
+		lo = ' '
+	}
+	if hi > '~' {
+		hi = '~'
+	}
+
+	return lo, hi
+}
+
+func (g *generation) generateRegex(b *strings.Builder, re *syntax.Regexp) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		b.WriteString(string(re.Rune))
+
+	case syntax.OpCharClass:
+		// Pick a printable ASCII character from the ranges, weighted by their size. Negated classes
+		// (e.g. [^,] or \S) run up to the last code point, which would almost always give an
+		// unassigned character, so the first character of the class is used if none is printable
+		total := 0
+		for i := 0; i < len(re.Rune); i += 2 {
+			if lo, hi := printableRange(re.Rune[i], re.Rune[i+1]); lo <= hi {
+				total += int(hi-lo) + 1
+			}
+		}
+		if total == 0 {
+			if len(re.Rune) > 0 {
+				b.WriteRune(re.Rune[0])
+			}
+			return
+		}
+		n := g.rand.Intn(total)
+		for i := 0; i < len(re.Rune); i += 2 {
+			lo, hi := printableRange(re.Rune[i], re.Rune[i+1])
+			if lo > hi {
+				continue
+			}
+			size := int(hi-lo) + 1
+			if n < size {
+				b.WriteRune(lo + rune(n))
+				return
+			}
+			n -= size
+		}
+
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		b.WriteByte(byte(' ' + g.rand.Intn('~'-' '+1)))
+
+	case syntax.OpCapture:
+		g.generateRegex(b, re.Sub[0])
+
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			g.generateRegex(b, sub)
+		}
+
+	case syntax.OpAlternate:
+		g.generateRegex(b, re.Sub[g.rand.Intn(len(re.Sub))])
+
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		min, max := re.Min, re.Max
+		switch re.Op {
+		case syntax.OpStar:
+			min, max = 0, -1
+		case syntax.OpPlus:
+			min, max = 1, -1
+		case syntax.OpQuest:
+			min, max = 0, 1
+		}
+		if max < 0 {
+			max = min + maxRegexRepeat
+		}
+		for n := min + g.rand.Intn(max-min+1); n > 0; n-- {
+			g.generateRegex(b, re.Sub[0])
+		}
+
+	default:
+		// Anchors, word boundaries and empty matches don't generate anything
+	}
+}
+
+// intValue returns an integer rule or generator value, or the default if it isn't set
+func intValue(v interface{}, defaultValue int) int {
+	if n, ok := integerValue(v); ok {
+		return int(n)
+	}
+
+	return defaultValue
+}
+
+// valueAt returns the value in the document at the concrete path (e.g. $.items[0])
+func valueAt(document interface{}, path docpath.Concrete) interface{} {
+	value := document
+	for _, fragment := range path[1:] {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if fragment.IsIndex {
+				return nil
+			}
+			value = v[fragment.Name]
+		case []interface{}:
+			if !fragment.IsIndex || fragment.Index < 0 || fragment.Index >= len(v) {
+				return nil
+			}
+			value = v[fragment.Index]
+		default:
+			return nil
+		}
+	}
+
+	return value
+}
+
+// setValue replaces the value in the document at the concrete path, returning the document
+// (which is the new value itself if the path is $)
+func setValue(document interface{}, path docpath.Concrete, value interface{}) interface{} {
+	if len(path) <= 1 {
+		return value
+	}
+
+	parent := valueAt(document, path[:len(path)-1])
+	fragment := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		if !fragment.IsIndex {
+			p[fragment.Name] = value
+		}
+	case []interface{}:
+		if fragment.IsIndex && fragment.Index >= 0 && fragment.Index < len(p) {
+			p[fragment.Index] = value
+		}
+	}
+
+	return document
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	plugin "github.com/pact-foundation/pact-plugin-template-golang/io_pact_plugin"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// testGenerator returns a generator of the type with the values
+func testGenerator(t *testing.T, generatorType string, values map[string]interface{}) *plugin.Generator {
+	t.Helper()
+	s, err := structpb.NewStruct(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &plugin.Generator{Type: generatorType, Values: s}
+}
+
+func TestGenerateRandomInt(t *testing.T) {
+	tests := []struct {
+		min, max int64
+	}{
+		{0, 0},
+		{1, 10},
+		{-5, 5},
+		{-10, -1},
+		{100, 101},
+	}
+
+	for _, test := range tests {
+		t.Run(strconv.FormatInt(test.min, 10)+".."+strconv.FormatInt(test.max, 10), func(t *testing.T) {
+			generator := testGenerator(t, "RandomInt", map[string]interface{}{"min": test.min, "max": test.max})
+			for seed := 0; seed < 100; seed++ {
+				value, err := newGeneration(nil, nil, seed, nil).generate(generator, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				n, ok := integerValue(value)
+				if !ok {
+					t.Fatalf("expected an integer, got %#v", value)
+				}
+				if n < test.min || n > test.max {
+					t.Fatalf("expected a value between %d and %d, got %d", test.min, test.max, n)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateRegex(t *testing.T) {
+	tests := []struct {
+		regex string
+		// Whether the regex allows printable ASCII characters, which are then the only ones generated
+		printable bool
+	}{
+		{`\d{3}-\d{4}`, true},
+		{`^[a-z]{2,5}$`, true},
+		{`(foo|bar)+baz?`, true},
+		{`[^,]+`, true},
+		{`\S+`, true},
+		{`\W+`, true},
+		{`\D+`, true},
+		{`.+`, true},
+		{`(?i)[a-c]x`, true},
+		{`ünïcödé`, false},
+		{`[^\x00-\x7f]`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.regex, func(t *testing.T) {
+			generator := testGenerator(t, "Regex", map[string]interface{}{"regex": test.regex})
+			for seed := 0; seed < 50; seed++ {
+				value, err := newGeneration(nil, nil, seed, nil).generate(generator, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				s, ok := value.(string)
+				if !ok {
+					t.Fatalf("expected a string, got %#v", value)
+				}
+				if !regexp.MustCompile(test.regex).MatchString(s) {
+					t.Fatalf("expected %q to match the regex", s)
+				}
+				if test.printable && strings.IndexFunc(s, func(r rune) bool { return r < ' ' || r > '~' }) >= 0 {
+					t.Fatalf("expected %q to only have printable ASCII characters", s)
+				}
+			}
+		})
+	}
+}
+
+// Dates and times are generated in the format given, or by default in the format of the matcher of the same type
+func TestGenerateDateTime(t *testing.T) {
+	const now = "2022-03-04T05:06:07+02:00"
+	tests := []struct {
+		generatorType string
+		values        map[string]interface{}
+		value         string
+		defaultFormat string
+	}{
+		{"Date", nil, "2022-03-04", defaultDateFormat},
+		{"Date", map[string]interface{}{"format": "dd/MM/yyyy"}, "04/03/2022", ""},
+		{"Date", map[string]interface{}{"expression": "tomorrow"}, "2022-03-05", defaultDateFormat},
+		{"Time", nil, "05:06:07", defaultTimeFormat},
+		{"Time", map[string]interface{}{"format": "HH:mm"}, "05:06", ""},
+		{"Time", map[string]interface{}{"expression": "noon"}, "12:00:00", defaultTimeFormat},
+		{"DateTime", nil, "2022-03-04T05:06:07+02:00", defaultDateTimeFormat},
+		{"DateTime", map[string]interface{}{"format": "yyyy-MM-dd HH:mm:ss"}, "2022-03-04 05:06:07", ""},
+		{"DateTime", map[string]interface{}{"expression": "yesterday @ midnight"}, "2022-03-03T00:00:00+02:00", defaultDateTimeFormat},
+	}
+
+	for _, test := range tests {
+		t.Run(test.generatorType+" "+test.value, func(t *testing.T) {
+			value, err := newGeneration(nil, nil, nil, now).generate(testGenerator(t, test.generatorType, test.values), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != test.value {
+				t.Fatalf("expected %q, got %#v", test.value, value)
+			}
+
+			// The value passes the matcher of the same type
+			format, _ := test.values["format"].(string)
+			if err := matchDateTime(map[string]interface{}{"format": format}, test.defaultFormat, value); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// The same seed gives the same contents, so that generated values can be reproduced
+func TestGeneratorSeed(t *testing.T) {
+	t.Setenv(generatorSeedEnvVar, "")
+	generators := map[string]*plugin.Generator{
+		"$.id":    testGenerator(t, "RandomInt", map[string]interface{}{"min": 1, "max": 1000000}),
+		"$.name":  testGenerator(t, "RandomString", map[string]interface{}{"size": 10}),
+		"$.code":  testGenerator(t, "Regex", map[string]interface{}{"regex": `[A-Z]{3}-\d{4}`}),
+		"$.token": testGenerator(t, "Uuid", nil),
+	}
+	body := []byte(`{"id": 1, "name": "a", "code": "ABC-1234", "token": ""}`)
+
+	first := newGeneration(generators, nil, 42, nil).generateBody(body)
+	if string(first) == string(body) {
+		t.Fatalf("expected the contents to be generated, got %s", first)
+	}
+	if second := newGeneration(generators, nil, 42, nil).generateBody(body); string(second) != string(first) {
+		t.Errorf("expected the same seed to give %s, got %s", first, second)
+	}
+	if other := newGeneration(generators, nil, 43, nil).generateBody(body); string(other) == string(first) {
+		t.Errorf("expected another seed to give different contents than %s", first)
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	tests := []struct {
+		generatorType string
+		values        map[string]interface{}
+		err           string
+	}{
+		{"RandomInt", map[string]interface{}{"min": 10, "max": 1}, "RandomInt max 1 is less than min 10"},
+		{"RandomDecimal", map[string]interface{}{"digits": 0}, "RandomDecimal digits must be at least 1"},
+		{"RandomHexadecimal", map[string]interface{}{"digits": 0}, "RandomHexadecimal digits must be at least 1"},
+		{"RandomString", map[string]interface{}{"size": 0}, "RandomString size must be at least 1"},
+		{"Regex", map[string]interface{}{"regex": "[a-"}, "invalid regex '[a-'"},
+		{"Date", map[string]interface{}{"expression": "someday"}, "someday"},
+		{"ProviderState", map[string]interface{}{"expression": "${id}"}, "no provider state parameters are available"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.generatorType, func(t *testing.T) {
+			_, err := newGeneration(nil, nil, 1, nil).generate(testGenerator(t, test.generatorType, test.values), nil)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected the error to contain '%s', got '%s'", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+// This file contains the journal of the exchanges handled by a mock server,
+// to help debug failing consumer tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	plugin "github.com/pact-foundation/pact-plugin-template-golang/io_pact_plugin"
+)
+
+// Set this environment variable to "true" to write each mock server's journal
+// to the log directory when it is shut down
+const journalEnvVar = "MOCK_SERVER_JOURNAL"
+
+// journalEntry records a single exchange handled by the mock server
+type journalEntry struct {
+	Timestamp time.Time
+	Request   []byte
+
+	// Key of the interaction the request matched, empty if it matched none
+	InteractionKey string
+
+	// If the request matched no interaction, the key of the closest interaction
+	// and the mismatches against it. Empty if there were no interactions to match
+	ClosestKey string
+	Mismatches []*plugin.ContentMismatch
+
+	// Response frames sent back to the consumer, if any
+	Responses [][]byte
+
+	// Transport fault injected into the response, if any
+	Fault string
+}
+
+// String summarises the entry on a single line, for use in mock server results
+func (e journalEntry) String() string {
+	interaction := e.InteractionKey
+	if interaction == "" {
+		interaction = "<none>"
+	}
+
+	responses := make([]string, len(e.Responses))
+	for i, r := range e.Responses {
+		responses[i] = printableBytes(r)
+	}
+
+	summary := fmt.Sprintf("[%s] request=%s interaction=%s responses=[%s]",
+		e.Timestamp.Format(time.RFC3339Nano), printableBytes(e.Request), interaction, strings.Join(responses, ", "))
+	if e.Fault != "" {
+		summary += " fault=" + e.Fault
+	}
+
+	return summary
+}
+
+// printableBytes quotes text content, and hex encodes anything else
+func printableBytes(b []byte) string {
+	if utf8.Valid(b) {
+		return fmt.Sprintf("%q", b)
+	}
+
+	return fmt.Sprintf("0x%x", b)
+}
+
+// journalRecord is the JSON representation of a journal entry
+type journalRecord struct {
+	Timestamp      time.Time         `json:"timestamp"`
+	Request        journalPayload    `json:"request"`
+	InteractionKey string            `json:"interactionKey,omitempty"`
+	ClosestKey     string            `json:"closestInteractionKey,omitempty"`
+	Mismatches     []journalMismatch `json:"mismatches,omitempty"`
+	Responses      []journalPayload  `json:"responses"`
+	Fault          string            `json:"fault,omitempty"`
+}
+
+// journalPayload holds text payloads as a string, and anything else as base64 encoded bytes
+type journalPayload struct {
+	Text  string `json:"text,omitempty"`
+	Bytes []byte `json:"bytes,omitempty"`
+}
+
+func newJournalPayload(b []byte) journalPayload {
+	if utf8.Valid(b) {
+		return journalPayload{Text: string(b)}
+	}
+
+	return journalPayload{Bytes: b}
+}
+
+type journalMismatch struct {
+	Path     string `json:"path"`
+	Mismatch string `json:"mismatch"`
+}
+
+// writeJournal writes the journal of a mock server to the log directory,
+// and returns the path of the file
+func writeJournal(serverKey string, entries []journalEntry) (string, error) {
+	records := make([]journalRecord, len(entries))
+	for i, e := range entries {
+		records[i] = journalRecord{
+			Timestamp:      e.Timestamp,
+			Request:        newJournalPayload(e.Request),
+			InteractionKey: e.InteractionKey,
+			ClosestKey:     e.ClosestKey,
+			Fault:          e.Fault,
+			Responses:      make([]journalPayload, len(e.Responses)),
+		}
+		for _, m := range e.Mismatches {
+			records[i].Mismatches = append(records[i].Mismatches, journalMismatch{Path: m.Path, Mismatch: m.Mismatch})
+		}
+		for j, r := range e.Responses {
+			records[i].Responses[j] = newJournalPayload(r)
+		}
+	}
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	dir := logDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	file := path.Join(dir, "mock-server-"+serverKey+"-journal.json")
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return "", err
+	}
+
+	return file, nil
+}
+
+// journalEnabled reports if the journal should be written to file on shutdown
+func journalEnabled() bool {
+	return strings.EqualFold(os.Getenv(journalEnvVar), "true")
+}
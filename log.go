@@ -22,11 +22,16 @@ const (
 
 var logFilter *logutils.LevelFilter
 
-func initLogging() {
+// logDir is the directory the plugin writes its logs and other artifacts to
+func logDir() string {
 	dir, _ := os.Getwd()
 
+	return path.Join(dir, "log")
+}
+
+func initLogging() {
 	lumberjackLogger := &lumberjack.Logger{
-		Filename:   path.Join(dir, "log", "plugin.log"),
+		Filename:   path.Join(logDir(), "plugin.log"),
 		MaxSize:    500, // megabytes
 		MaxBackups: 3,
 		MaxAge:     28,   //days
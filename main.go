@@ -12,14 +12,14 @@ import (
 func main() {
 	initLogging()
 
-	port, err := GetFreePort()
+	lis, err := Listen("127.0.0.1", 0)
 	if err != nil {
-		log.Fatal("ERROR unable to find a free port:", err)
+		log.Fatal("ERROR unable to bind the plugin server to a free port:", err)
 	}
 
 	// Start the Plugin Server
 	startPluginServer(serverDetails{
-		Port:      port,
+		Listener:  lis,
 		ServerKey: uuid.NewString(),
 	})
 }
@@ -0,0 +1,339 @@
+package main
+
+// This file contains the implementations of the standard Pact V4 matching rules
+// See https://github.com/pact-foundation/pact-specification/tree/version-4#matching-rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	plugin "github.com/pact-foundation/pact-plugin-template-golang/io_pact_plugin"
+)
+
+// Semantic version, as per https://semver.org
+var semverRegex = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+// ruleType normalises the different spellings of a matching rule type used by the
+// Pact implementations, e.g. "min-type", "minType" and "MIN_TYPE" are all "mintype"
+func ruleType(rule *plugin.MatchingRule) string {
+	return normaliseRuleType(rule.Type)
+}
+
+func normaliseRuleType(t string) string {
+	t = strings.ToLower(t)
+	t = strings.ReplaceAll(t, "-", "")
+	t = strings.ReplaceAll(t, "_", "")
+
+	switch t {
+	case "equalto":
+		return "equality"
+	case "timestamp":
+		return "datetime"
+	}
+
+	return t
+}
+
+// ruleValues returns the configuration of a matching rule
+func ruleValues(rule *plugin.MatchingRule) map[string]interface{} {
+	if rule.Values == nil {
+		return map[string]interface{}{}
+	}
+
+	return rule.Values.AsMap()
+}
+
+// applyRule applies a single matching rule to the actual value, returning an error
+// describing the mismatch if it doesn't match
+func applyRule(rule *plugin.MatchingRule, expected interface{}, actual interface{}) error {
+	values := ruleValues(rule)
+
+	switch ruleType(rule) {
+	case "equality":
+		if !valuesEqual(expected, actual) {
+			return fmt.Errorf("expected %s to be equal to %s", displayValue(actual), displayValue(expected))
+		}
+
+	case "regex":
+		regex, _ := values["regex"].(string)
+		r, err := regexp.Compile(regex)
+		if err != nil {
+			return fmt.Errorf("invalid regex '%s': %s", regex, err)
+		}
+		s, ok := primitiveString(actual)
+		if !ok || !r.MatchString(s) {
+			return fmt.Errorf("expected %s to match '%s'", displayValue(actual), regex)
+		}
+
+	case "type", "min", "max", "mintype", "maxtype", "minmaxtype":
+		if valueType(expected) != valueType(actual) {
+			return fmt.Errorf("expected %s (%s) to be the same type as %s (%s)",
+				displayValue(actual), valueType(actual), displayValue(expected), valueType(expected))
+		}
+		if a, ok := actual.([]interface{}); ok {
+			return matchLength(values, a)
+		}
+
+	case "notempty":
+		if err := matchNotEmpty(actual); err != nil {
+			return err
+		}
+		if expected != nil && valueType(expected) != valueType(actual) {
+			return fmt.Errorf("expected %s (%s) to be the same type as %s (%s)",
+				displayValue(actual), valueType(actual), displayValue(expected), valueType(expected))
+		}
+
+	case "eachkey", "eachvalue", "arraycontains":
+		// These apply to the entries of arrays and objects (see collections.go)
+
+	case "include":
+		include := fmt.Sprint(values["value"])
+		s, ok := primitiveString(actual)
+		if !ok || !strings.Contains(s, include) {
+			return fmt.Errorf("expected %s to include '%s'", displayValue(actual), include)
+		}
+
+	case "integer":
+		if _, ok := integerValue(actual); !ok {
+			return fmt.Errorf("expected %s to be an integer", displayValue(actual))
+		}
+
+	case "decimal":
+		f, ok := numberValue(actual)
+		if !ok || isWholeNumber(actual, f) {
+			return fmt.Errorf("expected %s to be a decimal number", displayValue(actual))
+		}
+
+	case "number":
+		if _, ok := numberValue(actual); !ok {
+			return fmt.Errorf("expected %s to be a number", displayValue(actual))
+		}
+
+	case "boolean":
+		switch v := actual.(type) {
+		case bool:
+		case string:
+			if v != "true" && v != "false" {
+				return fmt.Errorf("expected %s to be a boolean", displayValue(actual))
+			}
+		default:
+			return fmt.Errorf("expected %s to be a boolean", displayValue(actual))
+		}
+
+	case "null":
+		if actual != nil {
+			return fmt.Errorf("expected %s to be null", displayValue(actual))
+		}
+
+	case "date":
+		return matchDateTime(values, defaultDateFormat, actual)
+
+	case "time":
+		return matchDateTime(values, defaultTimeFormat, actual)
+
+	case "datetime":
+		return matchDateTime(values, defaultDateTimeFormat, actual)
+
+	case "contenttype":
+		return matchContentType(fmt.Sprint(values["value"]), actual)
+
+	case "semver":
+		s, ok := actual.(string)
+		if !ok || !semverRegex.MatchString(s) {
+			return fmt.Errorf("expected %s to be a semantic version", displayValue(actual))
+		}
+
+	default:
+		// Matchers defined by the plugin (see custommatchers.go)
+		if m, ok := findCustomMatcher(ruleType(rule)); ok {
+			return m.Match(values, expected, actual)
+		}
+		return fmt.Errorf("unsupported matching rule type '%s'", rule.Type)
+	}
+
+	return nil
+}
+
+// matchDateTime checks the actual value is a string in the format given by the rule
+func matchDateTime(values map[string]interface{}, defaultFormat string, actual interface{}) error {
+	format, _ := values["format"].(string)
+	if format == "" {
+		format = defaultFormat
+	}
+
+	s, ok := actual.(string)
+	if !ok {
+		return fmt.Errorf("expected %s to be a date/time string in the format '%s'", displayValue(actual), format)
+	}
+
+	var err error
+	if format == defaultDateTimeFormat {
+		_, err = time.Parse(time.RFC3339Nano, s)
+	} else {
+		_, err = parseWithPattern(format, s)
+	}
+	if err != nil {
+		return fmt.Errorf("expected %s to match the date/time format '%s': %s", displayValue(actual), format, err)
+	}
+
+	return nil
+}
+
+// matchContentType checks the content type detected from the actual value
+func matchContentType(expected string, actual interface{}) error {
+	s, ok := actual.(string)
+	if !ok {
+		return fmt.Errorf("expected %s to have the content type '%s'", displayValue(actual), expected)
+	}
+
+	expectedType, _, err := mime.ParseMediaType(expected)
+	if err != nil {
+		return fmt.Errorf("invalid content type '%s': %s", expected, err)
+	}
+
+	// JSON can't be detected by sniffing the content
+	if expectedType == "application/json" && json.Valid([]byte(s)) {
+		return nil
+	}
+
+	detected, _, _ := mime.ParseMediaType(http.DetectContentType([]byte(s)))
+	if detected != expectedType {
+		return fmt.Errorf("expected %s to have the content type '%s' but was '%s'", displayValue(actual), expected, detected)
+	}
+
+	return nil
+}
+
+// valueType returns the JSON type of a value
+func valueType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number, float64, int, int64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return reflect.TypeOf(v).String()
+	}
+}
+
+// valuesEqual compares two values, treating numbers with the same value as equal
+// regardless of how they are represented
+func valuesEqual(expected interface{}, actual interface{}) bool {
+	if valueType(expected) == "number" && valueType(actual) == "number" {
+		e, _ := numberValue(expected)
+		a, _ := numberValue(actual)
+		return e == a
+	}
+
+	return reflect.DeepEqual(expected, actual)
+}
+
+// numberValue converts a JSON number (or a string containing one, for text content) to a float
+func numberValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil && !math.IsInf(f, 0) && !math.IsNaN(f)
+	default:
+		return 0, false
+	}
+}
+
+// integerValue converts a JSON integer (or a string containing one, for text content) to an int
+func integerValue(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		i, err := n.Int64()
+		return i, err == nil
+	case float64:
+		return int64(n), n == math.Trunc(n)
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case string:
+		i, err := strconv.ParseInt(n, 10, 64)
+		return i, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// isWholeNumber reports if a number was written without a fractional part
+func isWholeNumber(v interface{}, f float64) bool {
+	switch n := v.(type) {
+	case json.Number:
+		return !strings.ContainsAny(n.String(), ".eE")
+	case string:
+		return !strings.ContainsAny(n, ".eE")
+	default:
+		return f == math.Trunc(f)
+	}
+}
+
+// primitiveString returns the string form of a primitive value, for matchers that work on text
+func primitiveString(v interface{}) (string, bool) {
+	switch p := v.(type) {
+	case string:
+		return p, true
+	case json.Number:
+		return p.String(), true
+	case bool:
+		return strconv.FormatBool(p), true
+	case float64:
+		return strconv.FormatFloat(p, 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// Longest value shown in a mismatch message. The full values are shown in the mismatch diff
+const maxDisplayLength = 100
+
+// displayValue formats a value for a mismatch message, truncating long values
+func displayValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("'%s'", truncate(s))
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return truncate(fmt.Sprint(v))
+	}
+
+	return truncate(string(b))
+}
+
+func truncate(s string) string {
+	runes := []rune(s)
+	if len(runes) <= maxDisplayLength {
+		return s
+	}
+
+	return fmt.Sprintf("%s... (%d characters)", string(runes[:maxDisplayLength]), len(runes))
+}
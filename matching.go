@@ -0,0 +1,387 @@
+package main
+
+// This file contains the content matching logic shared by CompareContents and the mock server
+// TODO: customise the matching to your content type
+//
+// Contents are parsed as JSON if possible, and otherwise treated as a single string value.
+// The expected and actual contents are walked together, and each value is checked with the
+// matching rules that apply to its path, or for equality if there are none.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/pact-foundation/pact-plugin-template-golang/docpath"
+	plugin "github.com/pact-foundation/pact-plugin-template-golang/io_pact_plugin"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// comparison holds the configuration for comparing an expected body to an actual one
+type comparison struct {
+	// Matching rules, keyed by path expression
+	rules map[string]*plugin.MatchingRules
+
+	// If keys in the actual content that are not in the expected content are allowed.
+	// Pact is strict with requests (false) and lenient with responses (true)
+	allowUnexpectedKeys bool
+
+	// If the contents are binary, in which case they are compared as bytes (see binary.go)
+	binary bool
+
+	// Limit on the number of mismatches reported for each path, zero for no limit
+	maxMismatches int
+
+	// The rule expressions, parsed on first use
+	parsedRules []docpath.Path
+}
+
+// Key in the pact configuration, and environment variable (which takes precedence), used to
+// limit the number of mismatches reported for each path
+const (
+	maxMismatchesKey    = "maxMismatchesPerPath"
+	maxMismatchesEnvVar = "MAX_MISMATCHES_PER_PATH"
+)
+
+// compareBodies compares the actual body against the expected one and returns all the mismatches found
+func (c *comparison) compareBodies(expected []byte, actual []byte) []*plugin.ContentMismatch {
+	var mismatches []*plugin.ContentMismatch
+	if c.binary {
+		mismatches = c.compareBinary(expected, actual)
+	} else {
+		mismatches = c.compare(docpath.RootPath(), parseContent(expected), parseContent(actual))
+	}
+
+	return capMismatches(mismatches, c.maxMismatches)
+}
+
+// maxMismatchesPerPath returns the limit on the number of mismatches reported for each path,
+// from the environment or otherwise the value configured in the pact. Zero means no limit
+func maxMismatchesPerPath(configured interface{}) int {
+	if env := os.Getenv(maxMismatchesEnvVar); env != "" {
+		if n, err := strconv.Atoi(env); err == nil && n >= 0 {
+			return n
+		}
+		log.Println("[WARN] ignoring invalid", maxMismatchesEnvVar, "value:", env)
+	}
+
+	if configured == nil {
+		return 0
+	}
+	n, ok := integerValue(configured)
+	if !ok || n < 0 {
+		log.Println("[WARN] ignoring invalid", maxMismatchesKey, "value:", configured)
+		return 0
+	}
+
+	return int(n)
+}
+
+// capMismatches keeps at most limit mismatches for each path, noting how many were left out
+func capMismatches(mismatches []*plugin.ContentMismatch, limit int) []*plugin.ContentMismatch {
+	if limit <= 0 {
+		return mismatches
+	}
+
+	counts := make(map[string]int)
+	for _, m := range mismatches {
+		counts[m.Path]++
+	}
+
+	var capped []*plugin.ContentMismatch
+	reported := make(map[string]int)
+	for _, m := range mismatches {
+		if reported[m.Path] == limit {
+			continue
+		}
+		reported[m.Path]++
+		if reported[m.Path] == limit && counts[m.Path] > limit {
+			m.Mismatch = fmt.Sprintf("%s (%d more mismatch(es) at this path were not reported)", m.Mismatch, counts[m.Path]-limit)
+		}
+		capped = append(capped, m)
+	}
+
+	return capped
+}
+
+// pluginRules converts the matching rules for a category in a pact file into the form used by the plugin interface
+func pluginRules(rules matchingRules, category string) map[string]*plugin.MatchingRules {
+	converted := make(map[string]*plugin.MatchingRules)
+
+	for expression, list := range rules[category] {
+		converted[expression] = &plugin.MatchingRules{}
+		for _, matcher := range list.Matchers {
+			rule, err := matchingRuleFromDefinition(matcher)
+			if err != nil {
+				log.Println("[WARN] ignoring invalid matching rule at", expression, ":", err)
+				continue
+			}
+			converted[expression].Rule = append(converted[expression].Rule, rule)
+		}
+	}
+
+	return converted
+}
+
+// parseContent parses the body as JSON, falling back to treating it as a plain string
+func parseContent(body []byte) interface{} {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil || decoder.More() {
+		return string(body)
+	}
+
+	return v
+}
+
+// compare recursively compares the value at path, returning all mismatches found
+func (c *comparison) compare(path docpath.Concrete, expected interface{}, actual interface{}) []*plugin.ContentMismatch {
+	rules, exact := c.rulesFor(path)
+	if !exact {
+		rules = inheritedRules(rules)
+	}
+
+	// Rules defined on an object or array apply to it as a whole, then its contents are
+	// compared as well, so that every mismatch is reported and not just the first
+	var mismatches []*plugin.ContentMismatch
+
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		if exact {
+			mismatches = c.applyRules(path, rules, expected, actual)
+		}
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			if len(mismatches) > 0 {
+				return mismatches
+			}
+			return []*plugin.ContentMismatch{c.mismatch(path, expected, actual,
+				fmt.Sprintf("expected an object but received %s", displayValue(actual)))}
+		}
+		return append(mismatches, c.compareObjects(path, e, a, rules, exact)...)
+
+	case []interface{}:
+		if exact {
+			mismatches = c.applyRules(path, rules, expected, actual)
+		}
+		a, ok := actual.([]interface{})
+		if !ok {
+			if len(mismatches) > 0 {
+				return mismatches
+			}
+			return []*plugin.ContentMismatch{c.mismatch(path, expected, actual,
+				fmt.Sprintf("expected an array but received %s", displayValue(actual)))}
+		}
+		return append(mismatches, c.compareArrays(path, e, a, rules, exact)...)
+
+	default:
+		if len(rules) == 0 {
+			rules = []*plugin.MatchingRule{{Type: "equality"}}
+		}
+		return c.applyRules(path, rules, expected, actual)
+	}
+}
+
+// compareObjects compares each of the expected keys, and checks for unexpected keys if they are not allowed.
+// With an eachKey or eachValue rule, the expected entries are only examples, so every actual entry is
+// checked instead, against the expected entry with the same key or otherwise the first expected entry.
+func (c *comparison) compareObjects(path docpath.Concrete, expected map[string]interface{}, actual map[string]interface{}, rules []*plugin.MatchingRule, exact bool) []*plugin.ContentMismatch {
+	var mismatches []*plugin.ContentMismatch
+
+	if exact && (findRule(rules, "eachkey") != nil || findRule(rules, "eachvalue") != nil) {
+		if rule := findRule(rules, "eachkey"); rule != nil {
+			mismatches = append(mismatches, c.matchKeys(path, rule, actual)...)
+		}
+
+		expectedKeys := sortedKeys(expected)
+		for _, key := range sortedKeys(actual) {
+			childPath := path.Field(key)
+			template, ok := expected[key]
+			switch {
+			case ok:
+			case len(expectedKeys) > 0:
+				template = expected[expectedKeys[0]]
+			default:
+				mismatches = append(mismatches, c.applyRules(childPath, inheritedRules(rules), nil, actual[key])...)
+				continue
+			}
+			mismatches = append(mismatches, c.compare(childPath, template, actual[key])...)
+		}
+
+		return mismatches
+	}
+
+	for _, key := range sortedKeys(expected) {
+		childPath := path.Field(key)
+		a, ok := actual[key]
+		if !ok {
+			mismatches = append(mismatches, c.mismatch(childPath, expected[key], nil,
+				fmt.Sprintf("expected key '%s' but it was missing", key)))
+			continue
+		}
+		mismatches = append(mismatches, c.compare(childPath, expected[key], a)...)
+	}
+
+	if !c.allowUnexpectedKeys {
+		for _, key := range sortedKeys(actual) {
+			if _, ok := expected[key]; !ok {
+				childPath := path.Field(key)
+				mismatches = append(mismatches, c.mismatch(childPath, nil, actual[key],
+					fmt.Sprintf("unexpected key '%s' was found", key)))
+			}
+		}
+	}
+
+	return mismatches
+}
+
+// compareArrays compares the arrays item by item. If a rule that allows the array to vary in length
+// applies to it (see variableLength), the actual array may be of a different length, with extra items
+// compared to the first expected item. Otherwise a difference in length is reported once, and only
+// the items in both arrays are compared. With an eachValue rule every item is compared to the first
+// expected item, and with an arrayContains rule the items are searched for each of the rule's variants.
+func (c *comparison) compareArrays(path docpath.Concrete, expected []interface{}, actual []interface{}, rules []*plugin.MatchingRule, exact bool) []*plugin.ContentMismatch {
+	if exact {
+		if rule := findRule(rules, "arraycontains"); rule != nil {
+			return c.arrayContains(path, rule, expected, actual)
+		}
+	}
+
+	var mismatches []*plugin.ContentMismatch
+	variable := variableLength(rules)
+	if !variable && len(expected) != len(actual) {
+		mismatches = append(mismatches, c.mismatch(path, expected, actual,
+			fmt.Sprintf("expected an array of length %d but received length %d", len(expected), len(actual))))
+	}
+
+	eachValue := exact && findRule(rules, "eachvalue") != nil
+	for i, a := range actual {
+		childPath := path.Item(i)
+		switch {
+		case i < len(expected) && !eachValue:
+			mismatches = append(mismatches, c.compare(childPath, expected[i], a)...)
+		case !variable:
+			// Extra items are already reported by the length mismatch
+		case len(expected) > 0:
+			mismatches = append(mismatches, c.compare(childPath, expected[0], a)...)
+		case eachValue:
+			mismatches = append(mismatches, c.applyRules(childPath, inheritedRules(rules), nil, a)...)
+		}
+	}
+
+	return mismatches
+}
+
+// variableLength reports if any of the rules allows an array to be of a different length than
+// expected, i.e. the type rules (with or without length bounds), notEmpty and eachValue
+func variableLength(rules []*plugin.MatchingRule) bool {
+	for _, rule := range rules {
+		switch ruleType(rule) {
+		case "type", "min", "max", "mintype", "maxtype", "minmaxtype", "notempty", "eachvalue":
+			return true
+		}
+	}
+
+	return false
+}
+
+// applyRules applies each of the rules to the value at path, returning a mismatch for every rule that fails
+func (c *comparison) applyRules(path docpath.Concrete, rules []*plugin.MatchingRule, expected interface{}, actual interface{}) []*plugin.ContentMismatch {
+	var mismatches []*plugin.ContentMismatch
+	for _, rule := range rules {
+		if err := applyRule(rule, expected, actual); err != nil {
+			mismatches = append(mismatches, c.mismatch(path, expected, actual, err.Error()))
+		}
+	}
+
+	return mismatches
+}
+
+// rulesFor returns the matching rules that apply to the path. Rules defined on a parent
+// cascade to its children, and when several expressions match the path the most specific
+// one wins (see docpath.Path.Weight). The second return value is true if the rules were
+// defined for the path itself rather than inherited.
+func (c *comparison) rulesFor(path docpath.Concrete) ([]*plugin.MatchingRule, bool) {
+	var best *docpath.Path
+	bestWeight := 0
+
+	for i, expression := range c.expressions() {
+		weight := expression.Weight(path)
+		if weight > bestWeight || (weight > 0 && weight == bestWeight && expression.Len() > best.Len()) {
+			best = &c.parsedRules[i]
+			bestWeight = weight
+		}
+	}
+
+	if best == nil {
+		return nil, false
+	}
+
+	return c.rules[best.String()].GetRule(), best.MatchesExactly(path)
+}
+
+// expressions parses the matching rule expressions, ignoring (and logging) any that are invalid
+func (c *comparison) expressions() []docpath.Path {
+	if c.parsedRules != nil {
+		return c.parsedRules
+	}
+
+	c.parsedRules = make([]docpath.Path, 0, len(c.rules))
+	for _, key := range sortedKeys(c.rules) {
+		if len(c.rules[key].GetRule()) == 0 {
+			continue
+		}
+		expression, err := docpath.Parse(key)
+		if err != nil {
+			log.Println("[WARN] ignoring matching rules:", err)
+			continue
+		}
+		c.parsedRules = append(c.parsedRules, expression)
+	}
+
+	return c.parsedRules
+}
+
+// mismatch builds a mismatch for the value at path
+func (c *comparison) mismatch(path docpath.Concrete, expected interface{}, actual interface{}, description string) *plugin.ContentMismatch {
+	return &plugin.ContentMismatch{
+		Expected: wrapperspb.Bytes(valueBytes(expected)),
+		Actual:   wrapperspb.Bytes(valueBytes(actual)),
+		Mismatch: description,
+		Path:     docpath.Format(path), // <- path where the content is matched.
+		Diff:     c.diff(expected, actual),
+
+		// The path can be denoted however you wish. Pact uses a JSON Path-like syntax
+		//
+		// Examples:
+		//
+		//   hierarchical => "$.foo.bar.baz...."
+		//   tabular =>      "column:1"
+	}
+}
+
+// valueBytes converts a value back to bytes for a mismatch. Strings are given as is
+func valueBytes(v interface{}) []byte {
+	if s, ok := v.(string); ok {
+		return []byte(s)
+	}
+
+	b, _ := json.Marshal(v)
+	return b
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
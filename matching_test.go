@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	plugin "github.com/pact-foundation/pact-plugin-template-golang/io_pact_plugin"
+)
+
+// testRule converts a matcher definition as found in a pact file (e.g. {"match": "integer"}) into a matching rule
+func testRule(t *testing.T, definition string) *plugin.MatchingRule {
+	t.Helper()
+	var d map[string]interface{}
+	if err := json.Unmarshal([]byte(definition), &d); err != nil {
+		t.Fatal(err)
+	}
+	rule, err := matchingRuleFromDefinition(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rule
+}
+
+func TestApplyRule(t *testing.T) {
+	tests := []struct {
+		definition string
+		expected   string
+		actual     string
+		err        string // Empty if the rule matches
+	}{
+		{`{"match": "equality"}`, `1`, `1.0`, ""},
+		{`{"match": "equality"}`, `"a"`, `"b"`, "expected 'b' to be equal to 'a'"},
+		{`{"match": "regex", "regex": "^\\d+$"}`, `"1"`, `"123"`, ""},
+		{`{"match": "regex", "regex": "^\\d+$"}`, `"1"`, `"12a"`, "expected '12a' to match"},
+		{`{"match": "type"}`, `"a"`, `"b"`, ""},
+		{`{"match": "type"}`, `"a"`, `1`, "to be the same type as"},
+		{`{"match": "type", "min": 1, "max": 2}`, `[1]`, `[1, 2]`, ""},
+		{`{"match": "type", "min": 1, "max": 2}`, `[1]`, `[]`, "at least 1 item(s) but received 0"},
+		{`{"match": "min", "min": 2}`, `[1]`, `[1, 2]`, ""},
+		{`{"match": "min", "min": 2}`, `[1]`, `[1]`, "at least 2 item(s) but received 1"},
+		{`{"match": "max", "max": 1}`, `[1]`, `[2]`, ""},
+		{`{"match": "max", "max": 1}`, `[1]`, `[1, 2]`, "at most 1 item(s) but received 2"},
+		{`{"match": "notEmpty"}`, `"a"`, `"b"`, ""},
+		{`{"match": "notEmpty"}`, `"a"`, `""`, "expected '' to not be empty"},
+		{`{"match": "include", "value": "ell"}`, `"hello"`, `"yellow"`, ""},
+		{`{"match": "include", "value": "ell"}`, `"hello"`, `"world"`, "expected 'world' to include 'ell'"},
+		{`{"match": "integer"}`, `1`, `42`, ""},
+		{`{"match": "integer"}`, `1`, `1.5`, "expected 1.5 to be an integer"},
+		{`{"match": "decimal"}`, `1.5`, `2.25`, ""},
+		{`{"match": "decimal"}`, `1.5`, `2`, "expected 2 to be a decimal number"},
+		{`{"match": "number"}`, `1`, `2.5`, ""},
+		{`{"match": "number"}`, `1`, `"x"`, "expected 'x' to be a number"},
+		{`{"match": "boolean"}`, `true`, `false`, ""},
+		{`{"match": "boolean"}`, `true`, `"yes"`, "expected 'yes' to be a boolean"},
+		{`{"match": "null"}`, `null`, `null`, ""},
+		{`{"match": "null"}`, `null`, `0`, "expected 0 to be null"},
+		{`{"match": "date"}`, `"2022-01-02"`, `"2023-12-31"`, ""},
+		{`{"match": "date"}`, `"2022-01-02"`, `"31/12/2023"`, "to match the date/time format 'yyyy-MM-dd'"},
+		{`{"match": "date", "format": "dd/MM/yyyy"}`, `"02/01/2022"`, `"31/12/2023"`, ""},
+		{`{"match": "time"}`, `"12:34:56"`, `"23:59:59"`, ""},
+		{`{"match": "time"}`, `"12:34:56"`, `"23:59"`, "to match the date/time format 'HH:mm:ss'"},
+		{`{"match": "datetime"}`, `"2022-01-02T03:04:05Z"`, `"2023-12-31T23:59:59+02:00"`, ""},
+		{`{"match": "datetime"}`, `"2022-01-02T03:04:05Z"`, `"2023-12-31 23:59:59"`, "to match the date/time format"},
+		{`{"match": "timestamp", "format": "yyyy-MM-dd HH:mm"}`, `"2022-01-02 03:04"`, `"2023-12-31 23:59"`, ""},
+		{`{"match": "contentType", "value": "application/json"}`, `"{}"`, `"{\"a\": 1}"`, ""},
+		{`{"match": "contentType", "value": "application/json"}`, `"{}"`, `"<html><body></body></html>"`, "but was 'text/html'"},
+		{`{"match": "semver"}`, `"1.0.0"`, `"1.2.3-beta.1"`, ""},
+		{`{"match": "semver"}`, `"1.0.0"`, `"1.2"`, "expected '1.2' to be a semantic version"},
+		{`{"match": "luhn"}`, `"4111 1111 1111 1111"`, `"4111 1111 1111 1111"`, ""},
+		{`{"match": "luhn"}`, `"4111 1111 1111 1111"`, `"4111 1111 1111 1112"`, "valid Luhn check digit"},
+		{`{"match": "unknown"}`, `1`, `1`, "unsupported matching rule type 'unknown'"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.definition+" "+test.actual, func(t *testing.T) {
+			err := applyRule(testRule(t, test.definition), parseContent([]byte(test.expected)), parseContent([]byte(test.actual)))
+			switch {
+			case test.err == "" && err != nil:
+				t.Errorf("expected the rule to match, got '%s'", err)
+			case test.err != "" && err == nil:
+				t.Error("expected an error")
+			case test.err != "" && !strings.Contains(err.Error(), test.err):
+				t.Errorf("expected the error to contain '%s', got '%s'", test.err, err)
+			}
+		})
+	}
+}
+
+func TestCompareBodies(t *testing.T) {
+	tests := []struct {
+		name                string
+		rules               map[string][]string
+		allowUnexpectedKeys bool
+		maxMismatches       int
+		expected            string
+		actual              string
+		paths               []string // The paths of the mismatches, in order
+		description         string   // Included in one of the mismatches, if given
+	}{
+		{
+			name:     "equal without rules",
+			expected: `{"a": 1, "b": [true, "x"]}`,
+			actual:   `{"b": [true, "x"], "a": 1.0}`,
+		},
+		{
+			name:     "different without rules",
+			expected: `{"a": 1, "b": [true, "x"]}`,
+			actual:   `{"a": 2, "b": [true, "y"]}`,
+			paths:    []string{"$.a", "$.b[1]"},
+		},
+		{
+			name:     "different array length without rules",
+			expected: `{"a": [1, 2]}`,
+			actual:   `{"a": [1]}`,
+			paths:    []string{"$.a"},
+		},
+		{
+			name:     "text contents",
+			rules:    map[string][]string{"$": {`{"match": "regex", "regex": "^h"}`}},
+			expected: "hello",
+			actual:   "hi",
+		},
+		{
+			name:     "text contents that don't match",
+			rules:    map[string][]string{"$": {`{"match": "regex", "regex": "^h"}`}},
+			expected: "hello",
+			actual:   "yo",
+			paths:    []string{"$"},
+		},
+		{
+			name:     "rules cascade to children",
+			rules:    map[string][]string{"$.a": {`{"match": "type"}`}},
+			expected: `{"a": {"b": "x", "c": [1]}}`,
+			actual:   `{"a": {"b": "y", "c": [2, 3]}}`,
+		},
+		{
+			name:     "rules cascade to children that don't match",
+			rules:    map[string][]string{"$.a": {`{"match": "type"}`}},
+			expected: `{"a": {"b": "x", "c": [1]}}`,
+			actual:   `{"a": {"b": 1, "c": [2, "3"]}}`,
+			paths:    []string{"$.a.b", "$.a.c[1]"},
+		},
+		{
+			name: "the most specific rule wins",
+			rules: map[string][]string{
+				"$.items[*].id": {`{"match": "integer"}`},
+				"$.items[1].id": {`{"match": "regex", "regex": "^x$"}`},
+			},
+			expected: `{"items": [{"id": 1}, {"id": "x"}]}`,
+			actual:   `{"items": [{"id": 2}, {"id": "x"}]}`,
+		},
+		{
+			name: "the most specific rule that doesn't match",
+			rules: map[string][]string{
+				"$.items[*].id": {`{"match": "integer"}`},
+				"$.items[1].id": {`{"match": "regex", "regex": "^x$"}`},
+			},
+			expected: `{"items": [{"id": 1}, {"id": "x"}]}`,
+			actual:   `{"items": [{"id": "y"}, {"id": 3}]}`,
+			paths:    []string{"$.items[0].id", "$.items[1].id"},
+		},
+		{
+			name:                "unexpected keys allowed",
+			allowUnexpectedKeys: true,
+			expected:            `{"a": 1}`,
+			actual:              `{"a": 1, "extra": 2}`,
+		},
+		{
+			name:     "unexpected keys not allowed",
+			expected: `{"a": 1}`,
+			actual:   `{"a": 1, "extra": 2}`,
+			paths:    []string{"$.extra"},
+		},
+		{
+			name:                "missing keys with unexpected keys allowed",
+			allowUnexpectedKeys: true,
+			expected:            `{"a": 1}`,
+			actual:              `{"b": 1}`,
+			paths:               []string{"$.a"},
+		},
+		{
+			name:     "min",
+			rules:    map[string][]string{"$.items": {`{"match": "type", "min": 2}`}},
+			expected: `{"items": ["a", "a"]}`,
+			actual:   `{"items": ["x", "y", "z"]}`,
+		},
+		{
+			name:     "min not reached",
+			rules:    map[string][]string{"$.items": {`{"match": "type", "min": 2}`}},
+			expected: `{"items": ["a", "a"]}`,
+			actual:   `{"items": ["x"]}`,
+			paths:    []string{"$.items"},
+		},
+		{
+			name:     "max",
+			rules:    map[string][]string{"$.items": {`{"match": "type", "max": 2}`}},
+			expected: `{"items": ["a"]}`,
+			actual:   `{"items": ["x", "y"]}`,
+		},
+		{
+			name:     "max exceeded, with an item of the wrong type",
+			rules:    map[string][]string{"$.items": {`{"match": "type", "max": 2}`}},
+			expected: `{"items": ["a"]}`,
+			actual:   `{"items": ["x", "y", 1]}`,
+			paths:    []string{"$.items", "$.items[2]"},
+		},
+		{
+			name:     "notEmpty",
+			rules:    map[string][]string{"$.items": {`{"match": "notEmpty"}`}},
+			expected: `{"items": ["a"]}`,
+			actual:   `{"items": ["b", "c"]}`,
+		},
+		{
+			name:     "notEmpty with an empty array",
+			rules:    map[string][]string{"$.items": {`{"match": "notEmpty"}`}},
+			expected: `{"items": ["a"]}`,
+			actual:   `{"items": []}`,
+			paths:    []string{"$.items"},
+		},
+		{
+			name:     "eachValue",
+			rules:    map[string][]string{"$.tags": {`{"match": "eachValue", "rules": [{"match": "regex", "regex": "^[a-z]+$"}]}`}},
+			expected: `{"tags": ["a"]}`,
+			actual:   `{"tags": ["b", "c", "d"]}`,
+		},
+		{
+			name:     "eachValue with a value that doesn't match",
+			rules:    map[string][]string{"$.tags": {`{"match": "eachValue", "rules": [{"match": "regex", "regex": "^[a-z]+$"}]}`}},
+			expected: `{"tags": ["a"]}`,
+			actual:   `{"tags": ["b", "1"]}`,
+			paths:    []string{"$.tags[1]"},
+		},
+		{
+			name: "eachKey",
+			rules: map[string][]string{"$.scores": {
+				`{"match": "eachKey", "rules": [{"match": "regex", "regex": "^[a-z]+$"}]}`,
+				`{"match": "eachValue", "rules": [{"match": "integer"}]}`,
+			}},
+			expected: `{"scores": {"maths": 1}}`,
+			actual:   `{"scores": {"art": 2, "maths": 3}}`,
+		},
+		{
+			name: "eachKey with a key that doesn't match",
+			rules: map[string][]string{"$.scores": {
+				`{"match": "eachKey", "rules": [{"match": "regex", "regex": "^[a-z]+$"}]}`,
+				`{"match": "eachValue", "rules": [{"match": "integer"}]}`,
+			}},
+			expected:    `{"scores": {"maths": 1}}`,
+			actual:      `{"scores": {"Art": 2, "maths": "x"}}`,
+			paths:       []string{"$.scores.Art", "$.scores.maths"},
+			description: "key 'Art': expected 'Art' to match '^[a-z]+$'",
+		},
+		{
+			name:     "arrayContains",
+			rules:    map[string][]string{"$.items": {`{"match": "arrayContains", "variants": [{"index": 0, "rules": {"$.id": {"matchers": [{"match": "integer"}]}}}]}`}},
+			expected: `{"items": [{"id": 1}]}`,
+			actual:   `{"items": [{"id": "x"}, {"id": 5}]}`,
+		},
+		{
+			name:        "arrayContains without a matching item",
+			rules:       map[string][]string{"$.items": {`{"match": "arrayContains", "variants": [{"index": 0, "rules": {"$.id": {"matchers": [{"match": "integer"}]}}}]}`}},
+			expected:    `{"items": [{"id": 1}]}`,
+			actual:      `{"items": [{"id": "x"}]}`,
+			paths:       []string{"$.items"},
+			description: "expected the array to contain an item matching variant 0",
+		},
+		{
+			name:     "every mismatch at a path without a limit",
+			rules:    map[string][]string{"$.a": {`{"match": "integer"}`, `{"match": "regex", "regex": "^x$"}`, `{"match": "boolean"}`}},
+			expected: `{"a": 1}`,
+			actual:   `{"a": "y"}`,
+			paths:    []string{"$.a", "$.a", "$.a"},
+		},
+		{
+			name:          "mismatches at a path over the limit",
+			rules:         map[string][]string{"$.a": {`{"match": "integer"}`, `{"match": "regex", "regex": "^x$"}`, `{"match": "boolean"}`}},
+			maxMismatches: 1,
+			expected:      `{"a": 1}`,
+			actual:        `{"a": "y"}`,
+			paths:         []string{"$.a"},
+			description:   "(2 more mismatch(es) at this path were not reported)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules := make(map[string]*plugin.MatchingRules)
+			for expression, definitions := range test.rules {
+				rules[expression] = &plugin.MatchingRules{}
+				for _, d := range definitions {
+					rules[expression].Rule = append(rules[expression].Rule, testRule(t, d))
+				}
+			}
+			c := comparison{
+				rules:               rules,
+				allowUnexpectedKeys: test.allowUnexpectedKeys,
+				maxMismatches:       test.maxMismatches,
+			}
+
+			mismatches := c.compareBodies([]byte(test.expected), []byte(test.actual))
+
+			var paths, descriptions []string
+			for _, m := range mismatches {
+				paths = append(paths, m.Path)
+				descriptions = append(descriptions, m.Mismatch)
+			}
+			if !reflect.DeepEqual(paths, test.paths) {
+				t.Errorf("expected mismatches at %v, got %v", test.paths, descriptions)
+			}
+			if test.description != "" && !strings.Contains(strings.Join(descriptions, "\n"), test.description) {
+				t.Errorf("expected a mismatch containing '%s', got %v", test.description, descriptions)
+			}
+		})
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+// This file contains the bookkeeping for the mock servers started by the driver
+// You probably don't need to change this, but the request handling will need
+// to be adapted to your protocol
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	plugin "github.com/pact-foundation/pact-plugin-template-golang/io_pact_plugin"
+)
+
+// How long a shutdown waits for in-flight exchanges to complete before
+// forcibly closing the remaining connections
+const shutdownTimeout = 5 * time.Second
+
+// mockServer holds the state of a single running mock server.
+//
+// Several mock servers may run at the same time (e.g. one per consumer test running
+// in parallel). Each one owns its listener, its interactions and its journal, and
+// nothing is shared between them, so a request received by one server is only ever
+// matched against that server's interactions.
+type mockServer struct {
+	key      string
+	url      string // Base URL, used by the MockServerURL generator
+	listener net.Listener
+
+	// The interactions the mock server can respond to, taken from the pact it was
+	// started with. They are not modified once the server is running
+	interactions []*syncMessageInteraction
+
+	// Limit on the mismatches reported for each path, from the pact configuration
+	maxMismatches int
+
+	// Seed and fixed time for the response generators, from the pact configuration
+	generatorSeed interface{}
+	generatorTime interface{}
+
+	// Closed when the accept loop has exited
+	done chan struct{}
+
+	mu      sync.Mutex
+	journal []journalEntry // Every exchange handled, in order
+	conns   map[net.Conn]struct{}
+	closing bool
+	active  int           // Number of in-flight exchanges
+	drained chan struct{} // Closed when the last in-flight exchange completes during shutdown
+}
+
+func newMockServer(key string, url string, listener net.Listener, pact pactv4) *mockServer {
+	maxMismatches, _ := pact.pluginConfiguration(maxMismatchesKey)
+	generatorSeed, _ := pact.pluginConfiguration(generatorSeedKey)
+	generatorTime, _ := pact.pluginConfiguration(generatorTimeKey)
+
+	return &mockServer{
+		key:           key,
+		url:           url,
+		listener:      listener,
+		interactions:  syncInteractions(pact),
+		maxMismatches: maxMismatchesPerPath(maxMismatches),
+		generatorSeed: generatorSeed,
+		generatorTime: generatorTime,
+		done:          make(chan struct{}),
+		conns:         make(map[net.Conn]struct{}),
+	}
+}
+
+// trackConnection registers an accepted connection, so that it can be closed on
+// shutdown. It returns false if the server is shutting down.
+func (s *mockServer) trackConnection(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closing {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+
+	return true
+}
+
+func (s *mockServer) untrackConnection(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conns, conn)
+}
+
+// beginExchange marks a request/response exchange as in-flight. It returns false
+// if the server is shutting down, in which case the request must not be handled.
+func (s *mockServer) beginExchange() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closing {
+		return false
+	}
+	s.active++
+
+	return true
+}
+
+// endExchange marks an in-flight exchange as complete
+func (s *mockServer) endExchange() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.active--
+	if s.active == 0 && s.drained != nil {
+		close(s.drained)
+		s.drained = nil
+	}
+}
+
+// shutdown stops accepting connections, then waits up to timeout for the in-flight
+// exchanges to complete before closing the listener and all remaining connections
+func (s *mockServer) shutdown(timeout time.Duration) error {
+	s.mu.Lock()
+	s.closing = true
+	var drained chan struct{}
+	if s.active > 0 {
+		drained = make(chan struct{})
+		s.drained = drained
+	}
+	s.mu.Unlock()
+
+	// Closing the listener is the only way to stop the accept loop
+	err := s.listener.Close()
+	<-s.done
+
+	if drained != nil {
+		select {
+		case <-drained:
+			log.Println("[DEBUG] mock server", s.key, "drained all in-flight exchanges")
+		case <-time.After(timeout):
+			log.Println("[WARN] mock server", s.key, "timed out after", timeout, "waiting for in-flight exchanges")
+		}
+	}
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	return err
+}
+
+// record appends an exchange to the journal
+func (s *mockServer) record(e journalEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.journal = append(s.journal, e)
+}
+
+// journalEntries returns a copy of the journal so far
+func (s *mockServer) journalEntries() []journalEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]journalEntry, len(s.journal))
+	copy(entries, s.journal)
+
+	return entries
+}
+
+// syncInteractions returns the interactions in the pact a mock server can respond to
+func syncInteractions(pact pactv4) []*syncMessageInteraction {
+	var interactions []*syncMessageInteraction
+	for _, inter := range pact.Interactions {
+		if i, ok := inter.(*syncMessageInteraction); ok {
+			interactions = append(interactions, i)
+		}
+	}
+
+	return interactions
+}
+
+// matchRequest finds the interaction matching the request body, returning the journal
+// entry for the exchange. If no interaction matches, nil is returned and the entry holds
+// the mismatches against the closest interaction (the one with the fewest mismatches).
+func (s *mockServer) matchRequest(body []byte) (*syncMessageInteraction, journalEntry) {
+	e := journalEntry{Timestamp: time.Now(), Request: body}
+
+	for _, i := range s.interactions {
+		// Pact is strict with requests, so unexpected keys are not allowed
+		expected, err := i.Request.Contents.bytes()
+		if err != nil {
+			log.Println("[ERROR] mock server", s.key, "unable to read the request for the interaction", i.Key, ":", err)
+			continue
+		}
+		c := comparison{
+			rules:         pluginRules(i.Request.MatchingRules, "body"),
+			binary:        i.Request.Contents.binary(),
+			maxMismatches: s.maxMismatches,
+		}
+
+		// Text is decoded using the charset of the interaction's content type, as the request
+		// is expected to be sent in the same charset (see charset.go)
+		actual := body
+		if !c.binary {
+			contentType := i.Request.Contents.ContentType
+			expected, err = decodeText(expected, contentType)
+			if err != nil {
+				log.Println("[ERROR] mock server", s.key, "unable to decode the request for the interaction", i.Key, ":", err)
+				continue
+			}
+			actual, err = decodeText(body, contentType)
+		}
+		var mismatches []*plugin.ContentMismatch
+		if err != nil {
+			mismatches = []*plugin.ContentMismatch{decodeMismatch(expected, body, err)}
+		} else {
+			mismatches = c.compareBodies(expected, actual)
+		}
+		if len(mismatches) == 0 {
+			e.InteractionKey = i.Key
+			e.ClosestKey = ""
+			e.Mismatches = nil
+			return i, e
+		}
+		if e.ClosestKey == "" || len(mismatches) < len(e.Mismatches) {
+			e.ClosestKey = i.Key
+			e.Mismatches = mismatches
+		}
+	}
+
+	return nil, e
+}
+
+// results summarises the exchanges handled by the mock server. There is an entry for every
+// request that did not match an interaction, and for every interaction that was never matched.
+// The journal entries are included in the errors, to help diagnose the failure.
+func (s *mockServer) results() []*plugin.MockServerResult {
+	results := make([]*plugin.MockServerResult, 0)
+	matched := make(map[string]bool)
+	interactions := s.interactions
+	journal := s.journalEntries()
+
+	for n, e := range journal {
+		if e.InteractionKey != "" {
+			matched[e.InteractionKey] = true
+			continue
+		}
+
+		if e.ClosestKey == "" {
+			results = append(results, &plugin.MockServerResult{
+				Error: fmt.Sprintf("unexpected request #%d received: %s", n+1, e),
+			})
+			continue
+		}
+
+		description := interactionDescription(interactions, e.ClosestKey)
+		results = append(results, &plugin.MockServerResult{
+			Path:       description,
+			Error:      fmt.Sprintf("request #%d did not match the interaction '%s': %s", n+1, description, e),
+			Mismatches: e.Mismatches,
+		})
+	}
+
+	for _, i := range interactions {
+		if !matched[i.Key] {
+			results = append(results, &plugin.MockServerResult{
+				Path:  i.Description,
+				Error: fmt.Sprintf("expected request for the interaction '%s' was not received%s", i.Description, journalSummary(journal)),
+			})
+		}
+	}
+
+	return results
+}
+
+// journalSummary lists the journal entries, one per line
+func journalSummary(journal []journalEntry) string {
+	if len(journal) == 0 {
+		return " (no requests were received)"
+	}
+
+	var b strings.Builder
+	b.WriteString(". Requests received:")
+	for n, e := range journal {
+		fmt.Fprintf(&b, "\n  #%d %s", n+1, e)
+	}
+
+	return b.String()
+}
+
+func interactionDescription(interactions []*syncMessageInteraction, key string) string {
+	for _, i := range interactions {
+		if i.Key == key {
+			return i.Description
+		}
+	}
+
+	return key
+}
+
+// mockServerRegistry tracks the running mock servers by their server key.
+// The driver may issue RPCs concurrently, so all access is guarded by a lock.
+type mockServerRegistry struct {
+	mu      sync.RWMutex
+	servers map[string]*mockServer
+}
+
+func newMockServerRegistry() *mockServerRegistry {
+	return &mockServerRegistry{
+		servers: make(map[string]*mockServer),
+	}
+}
+
+// add registers a new mock server. It is an error to register the same key twice
+func (r *mockServerRegistry) add(s *mockServer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.servers[s.key]; ok {
+		return fmt.Errorf("a mock server with key '%s' is already running", s.key)
+	}
+	r.servers[s.key] = s
+	log.Println("[DEBUG] registered mock server", s.key, "-", len(r.servers), "mock server(s) running")
+
+	return nil
+}
+
+// get finds a running mock server by its key
+func (r *mockServerRegistry) get(key string) (*mockServer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.servers[key]
+	if !ok {
+		return nil, fmt.Errorf("no mock server found with key '%s'", key)
+	}
+
+	return s, nil
+}
+
+// remove unregisters a mock server and returns it, so that it can be shut down
+func (r *mockServerRegistry) remove(key string) (*mockServer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.servers[key]
+	if !ok {
+		return nil, fmt.Errorf("no mock server found with key '%s'", key)
+	}
+	delete(r.servers, key)
+	log.Println("[DEBUG] unregistered mock server", key)
+
+	return s, nil
+}
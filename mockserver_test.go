@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	plugin "github.com/pact-foundation/pact-plugin-template-golang/io_pact_plugin"
+)
+
+// testPact returns a pact with a single synchronous message interaction
+func testPact(description string, request string, response string) string {
+	return fmt.Sprintf(`{
+		"consumer": {"name": "consumer"},
+		"provider": {"name": "provider"},
+		"interactions": [{
+			"type": "Synchronous/Messages",
+			"key": %[1]q,
+			"description": %[1]q,
+			"request": {"contents": {"content": %[2]q, "contentType": "application/foo"}},
+			"response": [{"contents": {"content": %[3]q, "contentType": "application/foo"}}]
+		}]
+	}`, description, request, response)
+}
+
+// Mock servers running at the same time must only ever match requests against their own
+// interactions, so a request meant for another server is reported as a mismatch
+func TestMockServersAreIsolated(t *testing.T) {
+	const servers = 8
+	m := newServer()
+
+	details := make([]*plugin.MockServerDetails, servers)
+	for n := range details {
+		res, err := m.StartMockServer(context.Background(), &plugin.StartMockServerRequest{
+			Pact: testPact(fmt.Sprintf("interaction %d", n), fmt.Sprintf("request %d", n), fmt.Sprintf("response %d", n)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e := res.GetError(); e != "" {
+			t.Fatal(e)
+		}
+		details[n] = res.GetDetails()
+	}
+	t.Cleanup(func() {
+		for _, d := range details {
+			m.ShutdownMockServer(context.Background(), &plugin.ShutdownMockServerRequest{ServerKey: d.Key})
+		}
+	})
+
+	for n, d := range details {
+		n, d := n, d
+		t.Run(fmt.Sprintf("server %d", n), func(t *testing.T) {
+			t.Parallel()
+
+			// Send the request of the next server
+			request := fmt.Sprintf("request %d", (n+1)%servers)
+			conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", fmt.Sprint(d.Port)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+			if err := writeFrame(conn, []byte(request)); err != nil {
+				t.Fatal(err)
+			}
+
+			// The mock server closes the connection when nothing matches
+			if response, err := readFrame(conn); err == nil {
+				t.Fatalf("expected the connection to be closed, but received %q", response)
+			}
+
+			res, err := m.GetMockServerResults(context.Background(), &plugin.MockServerRequest{ServerKey: d.Key})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Ok {
+				t.Fatal("expected the results not to be OK")
+			}
+
+			// The results also report that the server's own interaction was not received
+			description := fmt.Sprintf("interaction %d", n)
+			var mismatch *plugin.MockServerResult
+			for _, r := range res.Results {
+				if len(r.Mismatches) > 0 {
+					if mismatch != nil {
+						t.Fatalf("expected a single mismatched request, got %v", res.Results)
+					}
+					mismatch = r
+				}
+			}
+			if mismatch == nil {
+				t.Fatalf("expected a mismatch for the request, got %v", res.Results)
+			}
+			if mismatch.Path != description {
+				t.Errorf("expected the request to be matched against '%s', got '%s'", description, mismatch.Path)
+			}
+			if !strings.Contains(mismatch.Error, request) {
+				t.Errorf("expected the error to include the request %q, got %q", request, mismatch.Error)
+			}
+		})
+	}
+}
+
+// Requests are decoded using the charset of the interaction's content type before they are matched
+func TestMockServerDecodesRequests(t *testing.T) {
+	m := newServer()
+	res, err := m.StartMockServer(context.Background(), &plugin.StartMockServerRequest{
+		Pact: `{
+			"consumer": {"name": "consumer"},
+			"provider": {"name": "provider"},
+			"interactions": [{
+				"type": "Synchronous/Messages",
+				"key": "greeting",
+				"description": "greeting",
+				"request": {
+					"contents": {"content": "Y2Fm6Q==", "contentType": "application/foo;charset=ISO-8859-1", "encoded": "base64"},
+					"matchingRules": {"body": {"$": {"matchers": [{"match": "regex", "regex": "^(café|thé)$"}]}}}
+				},
+				"response": [{"contents": {"content": "merci", "contentType": "application/foo"}}]
+			}]
+		}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := res.GetError(); e != "" {
+		t.Fatal(e)
+	}
+	key := res.GetDetails().Key
+	defer m.ShutdownMockServer(context.Background(), &plugin.ShutdownMockServerRequest{ServerKey: key})
+
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", fmt.Sprint(res.GetDetails().Port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// "thé" in ISO-8859-1
+	if err := writeFrame(conn, []byte("th\xe9")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readFrame(conn); err != nil {
+		t.Fatalf("expected a response, got %s", err)
+	}
+
+	results, err := m.GetMockServerResults(context.Background(), &plugin.MockServerRequest{ServerKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results.Ok {
+		t.Errorf("expected the request to match, got %v", results.Results)
+	}
+}
@@ -1,20 +1,59 @@
 package main
 
-import "net"
-
-// GetFreePort Gets an available port by asking the kernal for a random port
-// ready and available for use.
-func GetFreePort() (int, error) {
-	addr, err := net.ResolveTCPAddr("tcp", "127.0.0.1:0")
-	if err != nil {
-		return 0, err
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Interface to bind to when the driver doesn't specify one
+const defaultHostInterface = "127.0.0.1"
+
+// Listen binds a TCP listener to the given host and port. A port of 0 asks the
+// kernel for a random free port; use listenerPort to find out which one.
+//
+// The listener should be handed straight to the server that will use it, rather
+// than closed and re-bound, so that another process can't grab the port in between.
+func Listen(host string, port int) (net.Listener, error) {
+	return net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+}
+
+// listenerPort returns the port a listener is bound to
+func listenerPort(l net.Listener) int {
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// normaliseHostInterface converts the host interface requested by the driver into
+// a host that can be bound to. IPv6 addresses may be given with or without brackets
+// (e.g. "::1" or "[::]"), and an empty value defaults to the loopback adapter.
+func normaliseHostInterface(host string) string {
+	host = strings.TrimSpace(host)
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	if host == "" {
+		return defaultHostInterface
 	}
 
-	l, err := net.ListenTCP("tcp", addr)
-	if err != nil {
-		return 0, err
+	return host
+}
+
+// connectableHost returns the host clients should connect to for a listener bound to host. A wildcard
+// address (0.0.0.0 or ::) listens on every interface but can't be reliably dialled, so the loopback
+// address of the same family is given instead
+func connectableHost(host string) string {
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsUnspecified() {
+		return host
+	}
+	if ip.To4() != nil {
+		return "127.0.0.1"
 	}
-	port := l.Addr().(*net.TCPAddr).Port
-	defer l.Close()
-	return port, nil
+
+	return net.IPv6loopback.String()
+}
+
+// formatAddress formats a host and port as a URL-style address, bracketing IPv6 hosts
+// e.g. tcp://127.0.0.1:1234 or tcp://[::1]:1234
+func formatAddress(scheme string, host string, port int) string {
+	return scheme + "://" + net.JoinHostPort(host, strconv.Itoa(port))
 }
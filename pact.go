@@ -1,9 +1,11 @@
 package main
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 )
 
 type pactv4 struct {
@@ -11,6 +13,30 @@ type pactv4 struct {
 	Provider        application
 	Interactions    []interface{}     `json:"-"` // This is polymorphic, so we need a custom marshaller
 	RawInteractions []json.RawMessage `json:"interactions"`
+	Metadata        pactMetadata
+}
+
+type pactMetadata struct {
+	Plugins []pluginMetadata
+}
+
+// The plugins used by the pact, along with the configuration they persisted to it
+type pluginMetadata struct {
+	Name          string
+	Version       string
+	Configuration map[string]interface{}
+}
+
+// pluginConfiguration returns a value that a plugin persisted to the pact metadata.
+// The configuration is keyed by the plugin name, so every plugin is checked for the key
+func (p pactv4) pluginConfiguration(key string) (interface{}, bool) {
+	for _, plugin := range p.Metadata.Plugins {
+		if v, ok := plugin.Configuration[key]; ok {
+			return v, true
+		}
+	}
+
+	return nil, false
 }
 
 func (p *pactv4) UnmarshalJSON(b []byte) error {
@@ -50,8 +76,12 @@ func (p *pactv4) UnmarshalJSON(b []byte) error {
 }
 
 type interaction struct {
-	Type string
-	Key  string
+	Type        string
+	Key         string
+	Description string
+
+	// Configuration persisted by plugins, keyed by the plugin name
+	PluginConfiguration map[string]json.RawMessage
 }
 
 type application struct {
@@ -72,11 +102,14 @@ type syncMessageInteraction struct {
 
 type asyncMessageInteraction struct {
 	interaction
-	Contents contents
+	Contents   contents
+	Generators generators
 }
 
 type messageRequest struct {
-	Contents contents
+	Contents      contents
+	MatchingRules matchingRules
+	Generators    generators
 }
 
 type httpResponse struct {
@@ -86,20 +119,81 @@ type httpResponse struct {
 // NOTE: only mapping parts of the spec required. Excluding headers, query etc.
 //       If you need additional fields please update and submit a PR
 type httpRequest struct {
-	Body bodyContent
+	Body       bodyContent
+	Generators generators
 }
 
 type syncMessageResponse struct {
-	Contents contents
+	Contents   contents
+	Generators generators
 }
 
 type contents struct {
-	Content string
+	Content         string
+	ContentType     string
+	ContentTypeHint string
+	Encoded         interface{} // false, or how the content is encoded in the pact file (true or "base64")
+}
+
+// bytes returns the contents, decoding them if they are encoded in the pact file
+func (c contents) bytes() ([]byte, error) {
+	return decodeContent(c.Content, c.Encoded)
+}
+
+// binary reports if the contents are binary, in which case they are compared as bytes
+func (c contents) binary() bool {
+	return strings.EqualFold(c.ContentTypeHint, "BINARY")
+}
+
+// Matching rules keyed by category (e.g. "body") and then by path expression
+type matchingRules map[string]map[string]matchingRuleList
+
+// Generators keyed by category (e.g. "body") and then by path expression. The "type" key
+// holds the generator type, the rest are its values
+type generators map[string]map[string]map[string]interface{}
+
+type matchingRuleList struct {
+	Combine  string
+	Matchers []map[string]interface{} // The "match" key holds the rule type, the rest are its values
 }
 
 type bodyContent struct {
 	Content         string // TODO: should be interface{} ?
 	ContentType     string
 	ContentTypeHint string
-	Encoded         bool
+	Encoded         interface{}
+}
+
+func (b bodyContent) bytes() ([]byte, error) {
+	return decodeContent(b.Content, b.Encoded)
+}
+
+func (b bodyContent) binary() bool {
+	return strings.EqualFold(b.ContentTypeHint, "BINARY")
+}
+
+// decodeContent decodes the content of a body from the pact file. Binary contents are stored base64
+// encoded, with the encoded attribute set to true or "base64" (both are used by the Pact implementations)
+func decodeContent(content string, encoded interface{}) ([]byte, error) {
+	switch e := encoded.(type) {
+	case nil:
+		return []byte(content), nil
+	case bool:
+		if !e {
+			return []byte(content), nil
+		}
+	case string:
+		if !strings.EqualFold(e, "base64") {
+			return nil, fmt.Errorf("unsupported content encoding '%s'", e)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported content encoding '%v'", e)
+	}
+
+	b, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 content: %w", err)
+	}
+
+	return b, nil
 }
@@ -2,19 +2,27 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	plugin "github.com/pact-foundation/pact-plugin-template-golang/io_pact_plugin"
 	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 var CONTENT_TYPE = "application/foo"
 
+// All the content types the plugin supports, which may include wildcards (see contenttype.go)
+var CONTENT_TYPES = []string{CONTENT_TYPE, "application/*+foo"}
+
 ///////////////////////////
 /// Common RPC functions //
 ///////////////////////////
@@ -41,20 +49,33 @@ func (m *pluginServer) InitPlugin(ctx context.Context, req *plugin.InitPluginReq
 
 	// TODO: update this as required
 	// NOTE: Not all plugins will implement both a CONTENT_MATCHER and TRANSPORT
-	return &plugin.InitPluginResponse{
-		Catalogue: []*plugin.CatalogueEntry{
-			{
-				Key:  "PROJECT NAME",                        // TODO: changeme!
-				Type: plugin.CatalogueEntry_CONTENT_MATCHER, // TODO: changeme!
-				Values: map[string]string{
-					"content-types": CONTENT_TYPE,
-				},
-			},
-			{
-				Key:  "TRANSPORT NAME", // TODO: changeme!
-				Type: plugin.CatalogueEntry_TRANSPORT,
+	catalogue := []*plugin.CatalogueEntry{
+		{
+			Key:  "PROJECT NAME",                        // TODO: changeme!
+			Type: plugin.CatalogueEntry_CONTENT_MATCHER, // TODO: changeme!
+			Values: map[string]string{
+				"content-types": strings.Join(CONTENT_TYPES, ";"),
 			},
 		},
+		{
+			Key:  "TRANSPORT NAME", // TODO: changeme!
+			Type: plugin.CatalogueEntry_TRANSPORT,
+		},
+	}
+
+	// Advertise the matching rule types the plugin defines (see custommatchers.go)
+	for _, matcher := range customMatchers {
+		catalogue = append(catalogue, &plugin.CatalogueEntry{
+			Key:  matcher.Name,
+			Type: plugin.CatalogueEntry_MATCHER,
+			Values: map[string]string{
+				"description": matcher.Description,
+			},
+		})
+	}
+
+	return &plugin.InitPluginResponse{
+		Catalogue: catalogue,
 	}, nil
 }
 
@@ -86,7 +107,7 @@ func (m *pluginServer) ConfigureInteraction(ctx context.Context, req *plugin.Con
 	// Remember - this structure is whatever you designed for your consumer interface
 	config, err := protoStructToConfigMap(req.ContentsConfig)
 
-	log.Println("Parsed ContentsConfig:", config.Request.Body, config.Response.Body, err)
+	log.Println("Parsed ContentsConfig:", config.Request.Body.value, config.Response.Body.value, err)
 
 	if err != nil {
 		log.Println("ERROR unmarshalling ContentsConfig from JSON:", err)
@@ -95,28 +116,119 @@ func (m *pluginServer) ConfigureInteraction(ctx context.Context, req *plugin.Con
 		}, nil
 	}
 
+	// Keep the content type the test asked for if it is one the plugin supports (e.g. application/vnd.bar+foo)
+	contentType := CONTENT_TYPE
+	if supportedContentType(req.ContentType) {
+		contentType = req.ContentType
+	} else if req.ContentType != "" {
+		log.Println("[WARN] content type", req.ContentType, "is not supported, using", CONTENT_TYPE)
+	}
+
 	var interactions = make([]*plugin.InteractionResponse, 0)
-	if config.Request.Body != "" {
-		interactions = append(interactions, &plugin.InteractionResponse{
-			Contents: &plugin.Body{
-				ContentType: CONTENT_TYPE,
-				Content:     wrapperspb.Bytes([]byte(config.Request.Body)), // <- ensure format is correct
-			},
-			PartName: "request",
-		})
+	if !config.Request.Body.empty() {
+		request, err := interactionPart("request", contentType, config.Request.Body)
+		if err != nil {
+			log.Println("ERROR invalid request body:", err)
+			return &plugin.ConfigureInteractionResponse{
+				Error: err.Error(),
+			}, nil
+		}
+		interactions = append(interactions, request)
 	}
-	if config.Response.Body != "" {
-		interactions = append(interactions, &plugin.InteractionResponse{
-			Contents: &plugin.Body{
-				ContentType: CONTENT_TYPE,
-				Content:     wrapperspb.Bytes([]byte(config.Response.Body)), // <- ensure format is correct
-			},
-			PartName: "response",
-		})
+	if !config.Response.Body.empty() {
+		response, err := interactionPart("response", contentType, config.Response.Body)
+		if err != nil {
+			log.Println("ERROR invalid response body:", err)
+			return &plugin.ConfigureInteractionResponse{
+				Error: err.Error(),
+			}, nil
+		}
+
+		// Persist any transport behaviour for the mock server to apply
+		if config.Response.Transport != (transportBehaviour{}) {
+			err = config.Response.Transport.validate()
+			if err != nil {
+				log.Println("ERROR invalid transport behaviour:", err)
+				return &plugin.ConfigureInteractionResponse{
+					Error: err.Error(),
+				}, nil
+			}
+
+			interactionConfiguration, err := config.Response.Transport.pluginConfiguration()
+			if err != nil {
+				return &plugin.ConfigureInteractionResponse{
+					Error: err.Error(),
+				}, nil
+			}
+			response.PluginConfiguration = &plugin.PluginConfiguration{
+				InteractionConfiguration: interactionConfiguration,
+			}
+		}
+
+		interactions = append(interactions, response)
+	}
+
+	// Settings that apply to the whole pact are persisted in its metadata
+	pactConfiguration := make(map[string]interface{})
+	if config.MaxMismatchesPerPath != 0 {
+		if config.MaxMismatchesPerPath < 0 {
+			return &plugin.ConfigureInteractionResponse{
+				Error: fmt.Sprintf("maxMismatchesPerPath must not be negative, got %d", config.MaxMismatchesPerPath),
+			}, nil
+		}
+		pactConfiguration[maxMismatchesKey] = config.MaxMismatchesPerPath
+	}
+	if config.ContentTypeMatching != "" {
+		if err := validContentTypeMatching(config.ContentTypeMatching); err != nil {
+			return &plugin.ConfigureInteractionResponse{
+				Error: err.Error(),
+			}, nil
+		}
+		pactConfiguration[contentTypeMatchingKey] = config.ContentTypeMatching
+	}
+	if config.GeneratorSeed != nil {
+		pactConfiguration[generatorSeedKey] = *config.GeneratorSeed
+	}
+	if config.GeneratorTime != "" {
+		if _, err := time.Parse(time.RFC3339, config.GeneratorTime); err != nil {
+			return &plugin.ConfigureInteractionResponse{
+				Error: fmt.Sprintf("generatorTime must be an RFC 3339 timestamp, got '%s'", config.GeneratorTime),
+			}, nil
+		}
+		pactConfiguration[generatorTimeKey] = config.GeneratorTime
+	}
+
+	var pluginConfiguration *plugin.PluginConfiguration
+	if len(pactConfiguration) > 0 {
+		s, _ := structpb.NewStruct(pactConfiguration)
+		pluginConfiguration = &plugin.PluginConfiguration{
+			PactConfiguration: s,
+		}
 	}
 
 	return &plugin.ConfigureInteractionResponse{
-		Interaction: interactions,
+		Interaction:         interactions,
+		PluginConfiguration: pluginConfiguration,
+	}, nil
+}
+
+// interactionPart builds the contents of a part of the interaction from its configured body. Any matching
+// expressions in the body are replaced by their example values, and their rules and generators are
+// returned with the contents (see expressions.go)
+func interactionPart(name string, contentType string, body configurationBody) (*plugin.InteractionResponse, error) {
+	content, rules, generators, err := body.contents()
+	if err != nil {
+		return nil, err
+	}
+
+	return &plugin.InteractionResponse{
+		Contents: &plugin.Body{
+			ContentType: contentType,
+			Content:     wrapperspb.Bytes(content),
+		},
+		Rules:      rules,
+		Generators: generators,
+		PartName:   name,
 	}, nil
 }
 
@@ -129,45 +241,77 @@ func (m *pluginServer) ConfigureInteraction(ctx context.Context, req *plugin.Con
 // Docs: https://github.com/pact-foundation/pact-plugins/blob/main/docs/content-matcher-design.md#match-content-requests
 func (m *pluginServer) CompareContents(ctx context.Context, req *plugin.CompareContentsRequest) (*plugin.CompareContentsResponse, error) {
 	log.Println("Received CompareContents request:", req)
-	var mismatch string
+	pactConfiguration := req.PluginConfiguration.GetPactConfiguration().AsMap()
+
+	// Check the content types are compatible before comparing the contents (see contenttype.go)
+	expectedType, actualType := req.Expected.GetContentType(), req.Actual.GetContentType()
+	if expectedType != "" && actualType != "" {
+		err := compareContentTypes(expectedType, actualType, contentTypeMatching(pactConfiguration[contentTypeMatchingKey]))
+		if err != nil {
+			log.Println("Content type mismatch found:", err)
+			return &plugin.CompareContentsResponse{
+				TypeMismatch: &plugin.ContentTypeMismatch{
+					Expected: expectedType,
+					Actual:   actualType,
+				},
+			}, nil
+		}
+	}
 
 	// Extract the actual and expected values (given as an array of bytes)
+	// and perform the matching logic (see matching.go).
 	// This is where you will need to convert and parse the protocol specific
 	// information
-	actual := string(req.Actual.Content.Value)
-	expected := string(req.Expected.Content.Value)
-
-	// Perform the matching logic.
-	// Here we are simply checking exact values
-	if compare(actual, expected) {
-		mismatch = fmt.Sprintf("expected body '%s' is not equal to actual body '%s'", expected, actual)
-		log.Println("Mismatch found:", mismatch)
-
-		return &plugin.CompareContentsResponse{
-			Results: map[string]*plugin.ContentMismatches{
-				"$": { // <- path where the content is matched.
-
-					// The path can be denoted however you wish. Pact uses a JSON Path-like syntax
-					//
-					// Examples:
-					//
-					//   hierarchical => "$.foo.bar.baz...."
-					//   tabular =>      "column:1"
-
-					Mismatches: []*plugin.ContentMismatch{
-						{
-							Expected: wrapperspb.Bytes([]byte(expected)),
-							Actual:   wrapperspb.Bytes([]byte(actual)),
-							Mismatch: mismatch,
-							Path:     "$",
-						},
-					},
-				},
-			},
-		}, nil
+	c := comparison{
+		rules:               req.Rules,
+		allowUnexpectedKeys: req.AllowUnexpectedKeys,
+		binary:              req.Expected.GetContentTypeHint() == plugin.Body_BINARY,
+		maxMismatches:       maxMismatchesPerPath(pactConfiguration[maxMismatchesKey]),
+	}
+	expected, actual := req.Expected.Content.GetValue(), req.Actual.Content.GetValue()
+
+	// Text is decoded using the charset of its content type (see charset.go). If the actual
+	// contents have no content type, they are assumed to be in the same charset as expected
+	if actualType == "" {
+		actualType = expectedType
+	}
+	var mismatches []*plugin.ContentMismatch
+	if !c.binary {
+		decodedExpected, err := decodeText(expected, expectedType)
+		if err != nil {
+			log.Println("ERROR unable to decode the expected contents:", err)
+			return &plugin.CompareContentsResponse{
+				Error: fmt.Sprintf("unable to decode the expected contents: %s", err),
+			}, nil
+		}
+		decodedActual, err := decodeText(actual, actualType)
+		if err != nil {
+			mismatches = append(mismatches, decodeMismatch(expected, actual, err))
+		} else {
+			expected, actual = decodedExpected, decodedActual
+		}
+	}
+	if len(mismatches) == 0 {
+		mismatches = c.compareBodies(expected, actual)
 	}
+	if len(mismatches) == 0 {
+		return &plugin.CompareContentsResponse{}, nil
+	}
+
+	// Group the mismatches by the path where the content was matched
+	results := make(map[string]*plugin.ContentMismatches)
+	for _, mismatch := range mismatches {
+		log.Println("Mismatch found:", mismatch.Path, mismatch.Mismatch)
 
-	return &plugin.CompareContentsResponse{}, nil
+		if _, ok := results[mismatch.Path]; !ok {
+			results[mismatch.Path] = &plugin.ContentMismatches{}
+		}
+		results[mismatch.Path].Mismatches = append(results[mismatch.Path].Mismatches, mismatch)
+	}
+
+	return &plugin.CompareContentsResponse{
+		Results: results,
+	}, nil
 }
 
 // Request to generate the content using any defined generators
@@ -184,25 +328,28 @@ func (m *pluginServer) CompareContents(ctx context.Context, req *plugin.CompareC
 func (m *pluginServer) GenerateContent(ctx context.Context, req *plugin.GenerateContentRequest) (*plugin.GenerateContentResponse, error) {
 	log.Println("Received GenerateContent request:", req.Contents, req.Generators, req.PluginConfiguration)
 
-	// Read in the Pact test configuration
-	// This is what is used in the test DSL to specify the plugin specific
-	// interaction details, such as request/response content
-	var config configuration
-	err := json.Unmarshal(req.Contents.Content.Value, &config)
+	// The contents are the part as it is sent over the wire, and are generated as they are
+	body := req.Contents.GetContent().GetValue()
 
-	if err != nil {
-		log.Println("ERROR:", err)
+	// Apply the generators (see generators.go). Binary contents are returned unchanged
+	if req.Contents.GetContentTypeHint() != plugin.Body_BINARY {
+		pactConfiguration := req.PluginConfiguration.GetPactConfiguration().AsMap()
+		body = newGeneration(req.Generators, generationContext(req.PluginConfiguration),
+			pactConfiguration[generatorSeedKey], pactConfiguration[generatorTimeKey]).generateBody(body)
+	}
+
+	contentType := req.Contents.GetContentType()
+	if contentType == "" {
+		contentType = CONTENT_TYPE
 	}
 
-	// Extract the portion of the message (request/response), and return the content as it will be sent
-	// over the wire
 	return &plugin.GenerateContentResponse{
 		Contents: &plugin.Body{
-			ContentType: CONTENT_TYPE,
-			Content:     wrapperspb.Bytes([]byte(config.Response.Body)),
+			ContentType:     contentType,
+			Content:         wrapperspb.Bytes(body),
+			ContentTypeHint: req.Contents.GetContentTypeHint(),
 		},
 	}, nil
-
 }
 
 ///////////////////////////////////////
@@ -218,50 +365,98 @@ func (m *pluginServer) StartMockServer(ctx context.Context, req *plugin.StartMoc
 	port := int(req.Port)
 
 	// Your server needs a unique id so that you can keep track of it
-	// The pact plugin framework may start several servers, so you
-	// may need to track each server separately (a task left up to the author)
+	// The pact plugin framework may start several servers, so each one is
+	// tracked separately in the mock server registry
 	id := uuid.NewString()
 	log.Println("Creating a new server with id:", id)
 
-	// If a port hasn't been specified, find a free one
-	// Return an error if one can't be allocated
-	if port == 0 {
-		port, err = GetFreePort()
+	// The mock server behaviour is driven by the Pact file
+	var p pactv4
+	err = json.Unmarshal([]byte(req.Pact), &p)
+	if err != nil {
+		log.Println("ERROR unable to parse the pact for the mock server:", err)
+		return &plugin.StartMockServerResponse{
+			Response: &plugin.StartMockServerResponse_Error{
+				Error: fmt.Sprintf("unable to parse the pact: %s", err),
+			},
+		}, nil
+	}
+
+	// Bind the listener up front and hand it straight to the mock server. If a port
+	// hasn't been specified, the OS will allocate a free one
+	host := normaliseHostInterface(req.HostInterface)
+	lis, err := Listen(host, port)
+	if err != nil {
+		log.Println("ERROR unable to start the mock server:", err)
+		return &plugin.StartMockServerResponse{
+			Response: &plugin.StartMockServerResponse_Error{
+				Error: err.Error(),
+			},
+		}, nil
+	}
+	port = listenerPort(lis)
+
+	// The server stays bound to the requested interface, but a wildcard interface is reported
+	// (and certified) as an address clients can connect to
+	host = connectableHost(host)
+	baseURL := formatAddress("tcp", host, port)
+	address := baseURL
+
+	// Serve over TLS if requested, using a freshly generated CA. The CA is written to
+	// the log directory so the consumer test can trust it, and is reported in the address
+	if req.Tls {
+		certs, err := generateCertificates(host)
+		if err == nil {
+			var caFile string
+			caFile, err = certs.writeCA(id)
+			lis = tls.NewListener(lis, certs.serverConfig())
+			baseURL = formatAddress("tcp+tls", host, port)
+			address = fmt.Sprintf("%s?ca=%s", baseURL, url.QueryEscape(caFile))
+			log.Println("[INFO] mock server", id, "is using TLS, CA certificate written to", caFile)
+		}
 		if err != nil {
-			log.Println("ERROR unable to find a free port:", err)
+			log.Println("ERROR unable to configure TLS for the mock server:", err)
+			lis.Close()
 			return &plugin.StartMockServerResponse{
 				Response: &plugin.StartMockServerResponse_Error{
-					Error: err.Error(),
+					Error: fmt.Sprintf("unable to configure TLS: %s", err),
 				},
-			}, err
+			}, nil
 		}
 	}
 
-	// TODO: start your server e.g.
-	// go startTCPServer(id, port)
+	s := newMockServer(id, baseURL, lis, p)
+	err = m.mockServers.add(s)
+	if err != nil {
+		lis.Close()
+		return &plugin.StartMockServerResponse{
+			Response: &plugin.StartMockServerResponse_Error{
+				Error: err.Error(),
+			},
+		}, nil
+	}
+
+	// Start the reference framed TCP server (see transport.go)
+	go s.serve()
 
 	// Populate the return message with your mock server details
-	// return &plugin.StartMockServerResponse{
-	// 	Response: &plugin.StartMockServerResponse_Details{
-	// 		Details: &plugin.MockServerDetails{
-	// 			Key:     id,
-	// 			Port:    uint32(port),
-	// 			Address: fmt.Sprintf("tcp://%s:%d", req.HostInterface, port),
-	// 		},
-	// 	},
-	// }, nil
-
-	return nil, nil
+	return &plugin.StartMockServerResponse{
+		Response: &plugin.StartMockServerResponse_Details{
+			Details: &plugin.MockServerDetails{
+				Key:     id,
+				Port:    uint32(port),
+				Address: address,
+			},
+		},
+	}, nil
 }
 
 // Shutdown a running mock server
 func (m *pluginServer) ShutdownMockServer(ctx context.Context, req *plugin.ShutdownMockServerRequest) (*plugin.ShutdownMockServerResponse, error) {
 	log.Println("Received ShutdownMockServer request:", req)
-	var err error
-
-	// Locate your server, and shut it down e.g.
-	// err := stopMyServer(req.ServerKey)
 
+	// Locate the server, and shut it down
+	s, err := m.mockServers.remove(req.ServerKey)
 	if err != nil {
 		return &plugin.ShutdownMockServerResponse{
 			Ok: false,
@@ -273,9 +468,26 @@ func (m *pluginServer) ShutdownMockServer(ctx context.Context, req *plugin.Shutd
 		}, nil
 	}
 
+	// Let in-flight exchanges finish before collecting the final results,
+	// so no mismatches are lost
+	err = s.shutdown(shutdownTimeout)
+	if err != nil {
+		log.Println("[WARN] error closing the listener for mock server", s.key, ":", err)
+	}
+	results := s.results()
+
+	if journalEnabled() {
+		file, err := writeJournal(s.key, s.journalEntries())
+		if err != nil {
+			log.Println("[WARN] unable to write the journal for mock server", s.key, ":", err)
+		} else {
+			log.Println("[INFO] journal for mock server", s.key, "written to", file)
+		}
+	}
+
 	return &plugin.ShutdownMockServerResponse{
-		Ok:      true,
-		Results: []*plugin.MockServerResult{},
+		Ok:      len(results) == 0,
+		Results: results,
 	}, nil
 
 }
@@ -284,13 +496,41 @@ func (m *pluginServer) ShutdownMockServer(ctx context.Context, req *plugin.Shutd
 func (m *pluginServer) GetMockServerResults(ctx context.Context, req *plugin.MockServerRequest) (*plugin.MockServerResults, error) {
 	log.Println("Received GetMockServerResults request:", req)
 
-	// TODO: error if server not called, or mismatches found
-
-	return &plugin.MockServerResults{}, nil
+	s, err := m.mockServers.get(req.ServerKey)
+	if err != nil {
+		return &plugin.MockServerResults{
+			Ok: false,
+			Results: []*plugin.MockServerResult{
+				{
+					Error: err.Error(),
+				},
+			},
+		}, nil
+	}
+	// Any unexpected requests, mismatches or interactions that were not
+	// called will result in a failure
+	results := s.results()
+	log.Println("Mock server", s.key, "received", len(s.journalEntries()), "request(s) with", len(results), "problem(s)")
+
+	return &plugin.MockServerResults{
+		Ok:      len(results) == 0,
+		Results: results,
+	}, nil
 }
 
 var requestMessage = ""
 var responseMessage = ""
+var responseContentType = ""
+var responseBinary = false
+
+// bodyString returns the (decoded) contents of a body in the pact, logging any error decoding them
+func bodyString(b []byte, err error) string {
+	if err != nil {
+		log.Println("ERROR unable to read the contents from the pact:", err)
+	}
+
+	return string(b)
+}
 
 // Prepare an interaction for verification. This should return any data required to construct any request
 // so that it can be amended before the verification is run e.g. auth headers
@@ -316,21 +556,34 @@ func (m *pluginServer) PrepareInteractionForVerification(ctx context.Context, re
 			log.Println("comparing keys", i.interaction.Key, req.InteractionKey)
 			if i.Key == req.InteractionKey {
 				log.Println("found HTTP interaction")
-				requestMessage = i.Request.Body.Content
-				responseMessage = i.Response.Body.Content
+				requestMessage = bodyString(i.Request.Body.bytes())
+				if !i.Request.Body.binary() {
+					requestMessage = verificationContents(p, i.Request.Generators, requestMessage, req.Config)
+				}
+				responseMessage = bodyString(i.Response.Body.bytes())
+				responseContentType = i.Response.Body.ContentType
+				responseBinary = i.Response.Body.binary()
 			}
 		case *asyncMessageInteraction:
 			log.Println("comparing keys", i.interaction.Key, req.InteractionKey)
 			if i.Key == req.InteractionKey {
 				log.Println("found async interaction")
-				requestMessage = i.Contents.Content
+				requestMessage = bodyString(i.Contents.bytes())
+				if !i.Contents.binary() {
+					requestMessage = verificationContents(p, i.Generators, requestMessage, req.Config)
+				}
 			}
 		case *syncMessageInteraction:
 			log.Println("comparing keys", i.interaction.Key, req.InteractionKey)
 			if i.Key == req.InteractionKey {
 				log.Println("found sync interaction")
-				requestMessage = i.Request.Contents.Content
-				responseMessage = i.Response[0].Contents.Content
+				requestMessage = bodyString(i.Request.Contents.bytes())
+				if !i.Request.Contents.binary() {
+					requestMessage = verificationContents(p, i.Request.Generators, requestMessage, req.Config)
+				}
+				responseMessage = bodyString(i.Response[0].Contents.bytes())
+				responseContentType = i.Response[0].Contents.ContentType
+				responseBinary = i.Response[0].Contents.binary()
 			}
 		default:
 			log.Printf("unknown interaction type: '%+v'", i)
@@ -353,6 +606,16 @@ func (m *pluginServer) PrepareInteractionForVerification(ctx context.Context, re
 
 }
 
+// verificationContents applies the body generators of the request (or message) sent to the provider,
+// with the provider state parameters given in the verification config
+func verificationContents(p pactv4, g generators, contents string, config *structpb.Struct) string {
+	context := map[string]interface{}{"providerState": config.AsMap()["providerState"]}
+	seed, _ := p.pluginConfiguration(generatorSeedKey)
+	now, _ := p.pluginConfiguration(generatorTimeKey)
+
+	return string(newGeneration(pluginGenerators(g, "body"), context, seed, now).generateBody([]byte(contents)))
+}
+
 // Execute the verification for the interaction.
 // TODO: delete this method if you are not providing a transport for your plugin
 func (m *pluginServer) VerifyInteraction(ctx context.Context, req *plugin.VerifyInteractionRequest) (*plugin.VerifyInteractionResponse, error) {
@@ -393,26 +656,46 @@ func (m *pluginServer) VerifyInteraction(ctx context.Context, req *plugin.Verify
 		}, nil
 	}
 
-	// Compare the expected vs actual, and print any errors
-	// this logic is likely insufficient, but it should help illuminate the point
-	if compare(actual, responseMessage) {
+	// Compare the expected vs actual, and print any errors (see matching.go).
+	// Pact is lenient with responses, so a provider may add fields the consumer doesn't use
+	var p pactv4
+	if err := json.Unmarshal([]byte(req.Pact), &p); err != nil {
+		log.Println("ERROR unable to parse the pact for verification:", err)
+	}
+	maxMismatches, _ := p.pluginConfiguration(maxMismatchesKey)
+	c := comparison{
+		allowUnexpectedKeys: true,
+		binary:              responseBinary,
+		maxMismatches:       maxMismatchesPerPath(maxMismatches),
+	}
+
+	// The provider responds in the charset of the expected response's content type (see charset.go)
+	var mismatches []*plugin.ContentMismatch
+	decoded := []byte(actual)
+	if !c.binary {
+		decoded, err = decodeText(decoded, responseContentType)
+	}
+	if err != nil {
+		mismatches = append(mismatches, decodeMismatch([]byte(responseMessage), []byte(actual), err))
+	} else {
+		mismatches = c.compareBodies([]byte(responseMessage), decoded)
+	}
+	if len(mismatches) > 0 {
+		items := make([]*plugin.VerificationResultItem, len(mismatches))
+		for i, mismatch := range mismatches {
+			items[i] = &plugin.VerificationResultItem{
+				Result: &plugin.VerificationResultItem_Mismatch{
+					Mismatch: mismatch,
+				},
+			}
+		}
+
 		return &plugin.VerifyInteractionResponse{
 			Response: &plugin.VerifyInteractionResponse_Result{
 				Result: &plugin.VerificationResult{
-					Success: false,
-					Output:  []string{""},
-					Mismatches: []*plugin.VerificationResultItem{
-						{
-							Result: &plugin.VerificationResultItem_Mismatch{
-								Mismatch: &plugin.ContentMismatch{
-									Expected: wrapperspb.Bytes([]byte(responseMessage)),
-									Actual:   wrapperspb.Bytes([]byte(actual)),
-									Path:     "$",                                                                // <- ensure path is correct
-									Mismatch: fmt.Sprintf("Expected '%s' but got '%s'", responseMessage, actual), // <- human readible error
-								},
-							},
-						},
-					},
+					Success:    false,
+					Output:     []string{""},
+					Mismatches: items,
 				},
 			},
 		}, nil
@@ -427,8 +710,3 @@ func (m *pluginServer) VerifyInteraction(ctx context.Context, req *plugin.Verify
 		},
 	}, nil
 }
-
-// TODO: review signature and return errors
-func compare(actual string, expected string) bool {
-	return actual != expected
-}
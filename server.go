@@ -13,20 +13,17 @@ import (
 )
 
 type serverDetails struct {
-	Port      int
+	Listener  net.Listener // Already bound, so the port can't be taken by another process
 	ServerKey string
 }
 
 func startPluginServer(details serverDetails) {
-	log.Println("starting server on port", details.Port)
-
-	lis, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", details.Port))
-	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
-	}
+	lis := details.Listener
+	port := listenerPort(lis)
+	log.Println("starting server on port", port)
 
 	// Required JSON structure for plugin framework to
-	fmt.Printf(`{"port": %d, "serverKey": "%s"}%s`, details.Port, details.ServerKey, "\n")
+	fmt.Printf(`{"port": %d, "serverKey": "%s"}%s`, port, details.ServerKey, "\n")
 
 	var opts []grpc.ServerOption
 
@@ -36,10 +33,15 @@ func startPluginServer(details serverDetails) {
 }
 
 func newServer() *pluginServer {
-	s := &pluginServer{}
+	s := &pluginServer{
+		mockServers: newMockServerRegistry(),
+	}
 	return s
 }
 
 type pluginServer struct {
 	plugin.UnimplementedPactPluginServer
+
+	// Mock servers started via StartMockServer, keyed by server key
+	mockServers *mockServerRegistry
 }
@@ -0,0 +1,132 @@
+package main
+
+// This file generates the certificates used when the driver asks for a TLS mock server
+// You probably don't need to change this
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path"
+	"time"
+)
+
+// Certificates are only used for the lifetime of a test run
+const certificateValidity = 24 * time.Hour
+
+// mockServerCertificates is a self-signed CA and a leaf certificate issued by it
+type mockServerCertificates struct {
+	// PEM encoded CA certificate, for consumers to add to their trust store
+	CAPEM []byte
+
+	// Server certificate and key, issued by the CA
+	Leaf tls.Certificate
+}
+
+// generateCertificates creates, in memory, a new CA and a leaf certificate valid for host.
+// The loopback addresses and "localhost" are always included as alternative names.
+func generateCertificates(host string) (*mockServerCertificates, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	caTemplate, err := certificateTemplate("Pact Plugin Mock Server CA")
+	if err != nil {
+		return nil, err
+	}
+	caTemplate.IsCA = true
+	caTemplate.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	caTemplate.BasicConstraintsValid = true
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, err
+	}
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	leafTemplate, err := certificateTemplate(host)
+	if err != nil {
+		return nil, err
+	}
+	leafTemplate.KeyUsage = x509.KeyUsageDigitalSignature
+	leafTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	leafTemplate.DNSNames = []string{"localhost"}
+	leafTemplate.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+
+	if ip := net.ParseIP(host); ip != nil {
+		leafTemplate.IPAddresses = append(leafTemplate.IPAddresses, ip)
+	} else if host != "" {
+		leafTemplate.DNSNames = append(leafTemplate.DNSNames, host)
+	}
+
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, ca, &leafKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mockServerCertificates{
+		CAPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		Leaf: tls.Certificate{
+			Certificate: [][]byte{leafDER, caDER},
+			PrivateKey:  leafKey,
+		},
+	}, nil
+}
+
+func certificateTemplate(commonName string) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{"Pact Foundation"},
+			CommonName:   commonName,
+		},
+		NotBefore: now.Add(-time.Minute),
+		NotAfter:  now.Add(certificateValidity),
+	}, nil
+}
+
+// serverConfig returns the TLS configuration to serve the leaf certificate with
+func (c *mockServerCertificates) serverConfig() *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{c.Leaf},
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// writeCA writes the CA certificate to the log directory, so that consumer
+// tests can trust it, and returns the path of the file
+func (c *mockServerCertificates) writeCA(serverKey string) (string, error) {
+	dir := logDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	file := path.Join(dir, "mock-server-"+serverKey+"-ca.pem")
+	if err := os.WriteFile(file, c.CAPEM, 0644); err != nil {
+		return "", err
+	}
+
+	return file, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+// This file contains a reference transport for the mock server: a simple framed
+// TCP protocol. Each message is sent as a 4 byte, big-endian length prefix
+// followed by the message payload.
+//
+// TODO: replace the framing and request handling with your own protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"time"
+)
+
+// Guard against allocating huge buffers for a corrupt or malicious length prefix
+const maxFrameSize = 16 * 1024 * 1024
+
+// readFrame reads a single length-prefixed message from the connection
+func readFrame(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxFrameSize {
+		return nil, fmt.Errorf("frame of %d bytes exceeds the maximum frame size of %d bytes", size, maxFrameSize)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// writeFrame writes a single length-prefixed message to the connection
+func writeFrame(w io.Writer, payload []byte) error {
+	frame := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[4:], payload)
+
+	_, err := w.Write(frame)
+	return err
+}
+
+// serve accepts connections until the listener is closed
+func (s *mockServer) serve() {
+	defer close(s.done)
+	log.Println("[DEBUG] mock server", s.key, "listening on", s.listener.Addr())
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Println("[ERROR] mock server", s.key, "unable to accept connection:", err)
+			}
+			return
+		}
+
+		go s.handleConnection(conn)
+	}
+}
+
+// handleConnection answers each request frame on the connection with the
+// response(s) of the matching interaction
+func (s *mockServer) handleConnection(conn net.Conn) {
+	defer conn.Close()
+	if !s.trackConnection(conn) {
+		return
+	}
+	defer s.untrackConnection(conn)
+	log.Println("[DEBUG] mock server", s.key, "accepted connection from", conn.RemoteAddr())
+
+	for {
+		body, err := readFrame(conn)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Println("[ERROR] mock server", s.key, "unable to read request:", err)
+			}
+			return
+		}
+		if !s.beginExchange() {
+			log.Println("[DEBUG] mock server", s.key, "is shutting down, ignoring request")
+			return
+		}
+		ok := s.exchange(conn, body)
+		s.endExchange()
+
+		if !ok {
+			return
+		}
+	}
+}
+
+// exchange handles a single request frame, returning false if the connection should be closed
+func (s *mockServer) exchange(conn net.Conn, body []byte) bool {
+	log.Println("[DEBUG] mock server", s.key, "received request:", string(body))
+
+	i, entry := s.matchRequest(body)
+	defer func() { s.record(entry) }()
+
+	if i == nil {
+		log.Println("[WARN] mock server", s.key, "has no interaction matching the request, closing the connection")
+		return false
+	}
+
+	// Apply any delays or faults the consumer configured for the interaction (see faults.go)
+	behaviour := transportBehaviourFor(i.interaction)
+	if delay := behaviour.delay(); delay > 0 {
+		log.Println("[DEBUG] mock server", s.key, "delaying the response by", delay)
+		time.Sleep(delay)
+	}
+	if behaviour.Fault != faultNone {
+		log.Println("[DEBUG] mock server", s.key, "injecting fault:", behaviour.Fault)
+		entry.Fault = behaviour.Fault
+	}
+
+	for _, response := range i.Response {
+		payload, err := response.Contents.bytes()
+		if err != nil {
+			log.Println("[ERROR] mock server", s.key, "unable to read the response:", err)
+			return false
+		}
+
+		// Apply the response generators, giving the MockServerURL generator this server's URL
+		if !response.Contents.binary() {
+			context := map[string]interface{}{"mockServer": map[string]interface{}{"href": s.url}}
+			payload = newGeneration(pluginGenerators(response.Generators, "body"), context, s.generatorSeed, s.generatorTime).generateBody(payload)
+		}
+		// Only what was actually sent is journalled, which is nothing when the connection is reset
+		written, connected, err := writeFaultyFrame(conn, payload, behaviour.Fault)
+		if err != nil {
+			log.Println("[ERROR] mock server", s.key, "unable to write response:", err)
+			return false
+		}
+		if written != nil {
+			entry.Responses = append(entry.Responses, written)
+		}
+
+		if !connected {
+			return false
+		}
+	}
+
+	return true
+}